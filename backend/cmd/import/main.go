@@ -0,0 +1,184 @@
+// Command import uploads a local ctfcli-style challenge repository to the deployer.
+//
+//	import -url https://deployer.local.lan -token $TOKEN ./challenges
+//
+// The token can also be given in the DEPLOYER_TOKEN environment variable.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type importResult struct {
+	Dir         string `json:"dir"`
+	Name        string `json:"name"`
+	ChallengeId string `json:"challengeid"`
+	Action      string `json:"action"`
+	Error       string `json:"error"`
+	Files       []struct {
+		Filename string   `json:"filename"`
+		Errors   []string `json:"errors"`
+	} `json:"files"`
+	Upload *struct {
+		Revision int `json:"revision"`
+	} `json:"upload"`
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8080", "URL of the deployer")
+	token := flag.String("token", os.Getenv("DEPLOYER_TOKEN"), "Bearer token of a developer or admin")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <repository directory>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	archive, err := packRepository(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Could not pack repository: %v", err)
+	}
+
+	results, err := upload(strings.TrimSuffix(*url, "/")+"/challenges/import", *token, archive)
+	if err != nil {
+		log.Fatalf("Could not import repository: %v", err)
+	}
+
+	failed := false
+	for _, result := range results {
+		switch result.Action {
+		case "failed":
+			failed = true
+			fmt.Printf("%-9s %s: %s\n", result.Action, result.Dir, result.Error)
+			for _, file := range result.Files {
+				for _, problem := range file.Errors {
+					fmt.Printf("          %s: %s\n", file.Filename, problem)
+				}
+			}
+		case "created", "updated":
+			fmt.Printf("%-9s %s: %s (%s revision %d)\n", result.Action, result.Dir, result.Name, result.ChallengeId, result.Upload.Revision)
+		default:
+			fmt.Printf("%-9s %s: %s (%s)\n", result.Action, result.Dir, result.Name, result.ChallengeId)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// packRepository returns a tar.gz archive of the regular files in dir, skipping hidden directories like .git
+func packRepository(dir string) ([]byte, error) {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && path != dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		err = writer.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func upload(url, token string, archive []byte) ([]importResult, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("repository", "repository.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write(archive)
+	if err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, content)
+	}
+
+	var response struct {
+		Challenges []importResult `json:"challenges"`
+	}
+	err = json.Unmarshal(content, &response)
+	return response.Challenges, err
+}
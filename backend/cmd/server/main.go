@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"deployer/config"
 	"deployer/internal/auth"
 	"deployer/internal/handlers"
 	"deployer/internal/infrastructure"
+	"deployer/internal/publisher"
+	"deployer/internal/reconciler"
 	"deployer/internal/storage"
+	"deployer/internal/uploads"
 	"log"
 	"net/http"
 	"os"
@@ -26,7 +30,7 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer <jwt-token>"
+// @description Type "Bearer <jwt-token>" or "Bearer <api-key>"
 func main() {
 	log.Println("Starting...")
 	logf.SetLogger(zap.New())
@@ -52,8 +56,29 @@ func main() {
 	}
 
 	storage.InitDb()
+	auth.TokenValid = storage.IsTokenValid
+	auth.FindApiKey = storage.FindApiKey
+	auth.LoadSigningKeys = storage.ListSigningKeys
+
+	err := uploads.MigrateLegacyLayout()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	err = uploads.MigrateRevisionArtifacts(context.Background())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	err = uploads.MigrateChallengeMetadata(context.Background())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	err = storage.MigratePublications(publisher.DefaultTarget())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
 	go infrastructure.StartCleaner()
+	go reconciler.Start()
 
 	router := gin.Default()
 	router.Use(ErrorHandler)
@@ -64,10 +89,62 @@ func main() {
 
 	router.POST("/users/login", handlers.Login)
 
+	router.POST("/users/login/totp", handlers.LoginTotp)
+
+	router.POST("/users/login/totp/setup", handlers.LoginTotpSetup)
+
+	router.POST("/users/refresh", handlers.RefreshToken)
+
+	router.POST("/users/logout", auth.RequireAuth, handlers.Logout)
+
+	router.PUT("/users/me/password", auth.RequireAuth, handlers.ChangePassword)
+
+	router.POST("/users/me/totp", auth.RequireAuth, handlers.SetupTotp)
+
+	router.POST("/users/me/totp/enable", auth.RequireAuth, handlers.EnableTotp)
+
+	router.POST("/users/me/totp/recovery-codes", auth.RequireAuth, handlers.RegenerateRecoveryCodes)
+
+	router.DELETE("/users/me/totp", auth.RequireAuth, handlers.DisableTotp)
+
+	router.GET("/users", auth.RequireAdmin, handlers.ListUsers)
+
+	router.POST("/users", auth.RequireAdmin, handlers.CreateUser)
+
+	router.DELETE("/users/:id", auth.RequireAdmin, handlers.DeleteUser)
+
+	router.PUT("/users/:id/role", auth.RequireAdmin, handlers.SetUserRole)
+
+	router.POST("/users/:id/disable", auth.RequireAdmin, handlers.DisableUser)
+
+	router.POST("/users/:id/enable", auth.RequireAdmin, handlers.EnableUser)
+
+	router.POST("/users/:id/password", auth.RequireAdmin, handlers.ResetUserPassword)
+
+	router.POST("/users/:id/sessions/revoke", auth.RequireAdmin, handlers.RevokeUserSessions)
+
+	router.DELETE("/users/:id/totp", auth.RequireAdmin, handlers.ResetUserTotp)
+
+	router.GET("/apikeys", auth.RequireAuth, handlers.ListApiKeys)
+
+	router.POST("/apikeys", auth.RequireAuth, handlers.CreateApiKey)
+
+	router.DELETE("/apikeys/:id", auth.RequireAuth, handlers.RevokeApiKey)
+
+	router.GET("/auth/keys", auth.RequireAdmin, handlers.ListSigningKeys)
+
+	router.POST("/auth/keys/rotate", auth.RequireAdmin, handlers.RotateSigningKey)
+
+	router.DELETE("/auth/keys/:kid", auth.RequireAdmin, handlers.DeleteSigningKey)
+
+	router.GET("/auth/login-failures", auth.RequireAdmin, handlers.ListLoginFailures)
+
 	router.GET("/challenges", auth.RequireDeveloper, handlers.ListChallenges)
 
 	router.POST("/challenges", auth.RequireDeveloper, handlers.AddChallenge)
 
+	router.POST("/challenges/import", auth.RequireDeveloper, handlers.ImportChallenges)
+
 	router.PUT("/challenges/:id", auth.RequireDeveloper, handlers.UpdateChallenge)
 
 	router.DELETE("/challenges/:id", auth.RequireAuth, handlers.DeleteChallenge)
@@ -84,6 +161,26 @@ func main() {
 
 	router.POST("/challenges/:id/publish", auth.RequireDeveloper, handlers.PublishChallenge)
 
+	router.POST("/challenges/:id/unpublish", auth.RequireDeveloper, handlers.UnpublishChallenge)
+
+	router.POST("/challenges/:id/promote", auth.RequireAdmin, handlers.PromoteChallenge)
+
+	router.GET("/challenges/:id/publications", auth.RequireDeveloper, handlers.ListChallengePublications)
+
+	router.POST("/challenges/:id/hide", auth.RequireDeveloper, handlers.HideChallenge)
+
+	router.POST("/challenges/:id/unhide", auth.RequireDeveloper, handlers.UnhideChallenge)
+
+	router.GET("/challenges/:id/revisions", auth.RequireDeveloper, handlers.ListChallengeRevisions)
+
+	router.GET("/challenges/:id/revisions/:revision/download", auth.RequireDeveloper, handlers.DownloadChallengeRevision)
+
+	router.POST("/challenges/:id/revisions/:revision/rollback", auth.RequireDeveloper, handlers.RollbackChallengeRevision)
+
+	router.POST("/challenges/:id/revisions/:revision/override", auth.RequireAdmin, handlers.OverrideChallengeRevisionPolicy)
+
+	router.POST("/challenges/:id/verify", auth.RequireAuth, handlers.VerifyChallengeFlag)
+
 	// TODO Add authentication to this endpoint, needs to be server-side
 	router.POST("/solutions/:id/verify", handlers.VerifyFlag)
 
@@ -95,9 +192,19 @@ func main() {
 
 	router.GET("/solutions/:id/logs", auth.RequireDeveloper, handlers.GetSolutionLogs)
 
+	router.GET("/submissions/shared", auth.RequireAdmin, handlers.ListSharedSubmissions)
+
+	router.GET("/ctfd/drift", auth.RequireAdmin, handlers.GetCtfdDrift)
+
+	router.POST("/ctfd/drift/repair", auth.RequireAdmin, handlers.RepairCtfdDrift)
+
+	router.GET("/backup/export", auth.RequireAdmin, handlers.ExportBackup)
+
+	router.POST("/backup/restore", auth.RequireAdmin, handlers.RestoreBackup)
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	err := router.SetTrustedProxies(nil)
+	err = router.SetTrustedProxies(config.Values.TrustedProxies)
 	if err != nil {
 		log.Fatal(err)
 	}
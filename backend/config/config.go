@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -25,7 +26,13 @@ type Config struct {
 	DbName                   string
 	DbConn                   string
 	JwtSecret                []byte
+	Tokens                   TokensConfig
+	LoginThrottle            LoginThrottleConfig
+	Totp                     TotpConfig
 	UploadPath               string
+	UploadStore              string
+	S3                       S3Config
+	UploadLimits             UploadLimitsConfig
 	MinVMMemory              string
 	MaxVMMemory              string
 	VMCPUs                   uint32
@@ -40,18 +47,54 @@ type Config struct {
 	AdminPassword            string
 	CTFDURL                  string
 	CTFDAPIToken             string
+	Publisher                PublisherConfig
+	PublishTargets           PublishTargets
+	DefaultPublishTarget     string `default:"default"`
+	Reconcile                ReconcileConfig
 	IngressClassName         string
 	IngressHttpAnnotations   Annotations
 	JwksUrl                  string
+	Oidc                     OidcConfig
 	RootCert                 string
 	ImagePullSecret          string
 	Unleash                  UnleashConfig
 	ChallengeReadinessProbe  KubernetesProbeConfig
 	ChallengeLivenessProbe   KubernetesProbeConfig
+	// Addresses or CIDRs of reverse proxies, e.g. the ingress controller, whose X-Forwarded-For header gives the client IP.
+	// Without any, the IP of the connection is used, which behind an ingress is the ingress for every client.
+	TrustedProxies []string
 	// Currently not supported
 	ChallengeStartupProbe KubernetesProbeConfig
 }
 
+// Lifetimes of the tokens of local users
+type TokensConfig struct {
+	AccessMinutes int `default:"15"`
+	// Sessions last this long after logging in, refreshing rotates the refresh token but does not extend them
+	RefreshDays int `default:"30"`
+}
+
+// Limits on failed logins of local users
+type LoginThrottleConfig struct {
+	// Failed logins per username and per IP before further attempts have to wait
+	FreeAttempts   int `default:"5"`
+	IpFreeAttempts int `default:"20"`
+	// Wait after the first failure over the limit, doubled by every further failure
+	BackoffSeconds int `default:"2"`
+	// Longest wait, reached after repeated failures. Failures are forgotten after this time without one.
+	LockoutMinutes int `default:"15"`
+	// Days failed logins are kept for auditing
+	AuditRetentionDays int `default:"90"`
+}
+
+// Two-factor authentication of local users with TOTP
+type TotpConfig struct {
+	// Shown in authenticator apps
+	Issuer string `default:"CTF Deployer"`
+	// Roles whose users must enroll TOTP at their next login, e.g. "admin"
+	RequiredRoles []string
+}
+
 type KubernetesProbeConfig struct {
 	InitialDelaySeconds int32
 	PeriodSeconds       int32
@@ -59,6 +102,86 @@ type KubernetesProbeConfig struct {
 	FailureThreshold    int32
 }
 
+// Limits enforced on uploaded challenge files
+type UploadLimitsConfig struct {
+	MaxFileBytes         int64 `default:"10485760"`
+	MaxArchiveEntries    int   `default:"1000"`
+	MaxUncompressedBytes int64 `default:"104857600"`
+	// Limit of the files in a repository imported in bulk
+	MaxImportBytes int64 `default:"104857600"`
+}
+
+type S3Config struct {
+	Endpoint       string
+	Region         string
+	Bucket         string
+	AccessKey      string
+	SecretKey      string
+	ForcePathStyle bool
+}
+
+// Scoreboard platform challenges are published to
+type PublisherConfig struct {
+	// "ctfd" (CTFDURL and CTFDAPITOKEN) or "webhook"
+	Type          string `default:"ctfd"`
+	WebhookUrl    string
+	WebhookSecret string
+}
+
+// Named scoreboard platforms, e.g. staging and production, given as JSON:
+// {"staging": {"type": "ctfd", "ctfd_url": "...", "ctfd_api_token": "..."}}
+type PublishTargets map[string]PublishTargetConfig
+
+func (t *PublishTargets) Decode(value string) error {
+	// An empty variable leaves the single platform configuration in place
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(value), t)
+}
+
+type PublishTargetConfig struct {
+	// "ctfd" or "webhook"
+	Type          string `json:"type"`
+	CtfdUrl       string `json:"ctfd_url"`
+	CtfdApiToken  string `json:"ctfd_api_token"`
+	WebhookUrl    string `json:"webhook_url"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// Periodic comparison of the published challenges with CTFd
+type ReconcileConfig struct {
+	IntervalMinutes int `default:"10"`
+	Repair          bool
+}
+
+// Tokens of an OpenID Connect identity provider. Setting the issuer replaces JWKSURL.
+// The roles claim is also used with JWKSURL, where it defaults to the Keycloak layout.
+type OidcConfig struct {
+	// The JWKS is found through <issuer>/.well-known/openid-configuration, and tokens must be issued by it
+	Issuer string
+	// Tokens must have this audience if set
+	Audience string
+	// Minutes between fetching the keys of the identity provider again
+	JwksRefreshMinutes int `default:"60"`
+	// Dot separated path to the roles in the token
+	RolesClaim string `default:"resource_access.deployer.roles"`
+	// Dot separated path to the groups in the token, e.g. "groups". Groups only count through the role mapping.
+	GroupsClaim string
+	// Roles and groups of the identity provider mapped to admin, developer or player, given as JSON: {"ctf-admins": "admin"}.
+	// Roles that are not mapped count if they are named admin or developer.
+	RoleMapping RoleMapping
+}
+
+type RoleMapping map[string]string
+
+func (m *RoleMapping) Decode(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(value), m)
+}
+
 type UnleashConfig struct {
 	Url         string
 	ApiKey      string
@@ -79,5 +202,33 @@ func initConfig() Config {
 			cfg.DbUser, cfg.DbPassword, cfg.DbHost, cfg.DbPort, cfg.DbName)
 	}
 
+	// Without named targets, publish to the single platform configured by CTFDURL or PUBLISHER_*
+	if len(cfg.PublishTargets) == 0 {
+		cfg.PublishTargets = PublishTargets{
+			cfg.DefaultPublishTarget: {
+				Type:          cfg.Publisher.Type,
+				CtfdUrl:       cfg.CTFDURL,
+				CtfdApiToken:  cfg.CTFDAPIToken,
+				WebhookUrl:    cfg.Publisher.WebhookUrl,
+				WebhookSecret: cfg.Publisher.WebhookSecret,
+			},
+		}
+	}
+	if _, ok := cfg.PublishTargets[cfg.DefaultPublishTarget]; !ok {
+		log.Fatalf("Default publish target '%s' is not configured", cfg.DefaultPublishTarget)
+	}
+
+	for name, role := range cfg.Oidc.RoleMapping {
+		if role != "admin" && role != "developer" && role != "player" {
+			log.Fatalf("OIDC role mapping of '%s' must be admin, developer or player, got '%s'", name, role)
+		}
+	}
+
+	for _, role := range cfg.Totp.RequiredRoles {
+		if role != "admin" && role != "developer" {
+			log.Fatalf("TOTP can only be required for the admin and developer roles, got '%s'", role)
+		}
+	}
+
 	return cfg
 }
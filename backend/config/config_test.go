@@ -0,0 +1,48 @@
+package config
+
+import "testing"
+
+func TestPublishTargetsDecode(t *testing.T) {
+	for _, value := range []string{"", "  ", "\n"} {
+		var targets PublishTargets
+		if err := targets.Decode(value); err != nil {
+			t.Errorf("Decode(%q) = %v, want nil", value, err)
+		}
+		if len(targets) != 0 {
+			t.Errorf("Decode(%q) = %v, want no targets", value, targets)
+		}
+	}
+
+	var targets PublishTargets
+	err := targets.Decode(`{"staging": {"type": "ctfd", "ctfd_url": "http://ctfd-staging"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets["staging"].CtfdUrl != "http://ctfd-staging" {
+		t.Errorf("staging target = %+v", targets["staging"])
+	}
+
+	if err := targets.Decode("{"); err == nil {
+		t.Error("Decode of invalid JSON succeeded")
+	}
+}
+
+func TestRoleMappingDecode(t *testing.T) {
+	for _, value := range []string{"", "  ", "\n"} {
+		var mapping RoleMapping
+		if err := mapping.Decode(value); err != nil {
+			t.Errorf("Decode(%q) = %v, want nil", value, err)
+		}
+		if len(mapping) != 0 {
+			t.Errorf("Decode(%q) = %v, want no mapping", value, mapping)
+		}
+	}
+
+	var mapping RoleMapping
+	if err := mapping.Decode(`{"ctf-admins": "admin"}`); err != nil {
+		t.Fatal(err)
+	}
+	if mapping["ctf-admins"] != "admin" {
+		t.Errorf("mapping = %v", mapping)
+	}
+}
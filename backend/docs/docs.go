@@ -5,7 +5,7 @@ package docs
 import "github.com/swaggo/swag"
 
 const docTemplate = `{
-    "schemes": {{ marshal .Schemes }},"swagger":"2.0","info":{"description":"{{escape .Description}}","title":"{{.Title}}","contact":{},"version":"{{.Version}}"},"host":"{{.Host}}","basePath":"{{.BasePath}}","paths":{"/challenges":{"get":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Callenges List","responses":{}},"post":{"security":[{"BearerAuth":[]}],"consumes":["multipart/form-data"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Add","parameters":[{"type":"array","items":{"type":"file"},"collectionFormat":"multi","description":"Allowed filenames: challenge.yml, challenge.zip, handout.zip, solution.zip","name":"upload[]","in":"formData","required":true}],"responses":{}}},"/challenges/{id}":{"put":{"security":[{"BearerAuth":[]}],"consumes":["multipart/form-data"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Update","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"array","items":{"type":"file"},"collectionFormat":"multi","description":"Allowed filenames: challenge.yml, challenge.zip, handout.zip, solution.zip","name":"upload[]","in":"formData","required":true}],"responses":{}},"delete":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Delete","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/download":{"get":{"description":"Downloads a challenge","tags":["challenges"],"summary":"Download challenge","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Token","name":"token","in":"query","required":true}],"responses":{"200":{"description":"Challenge file","schema":{"type":"file"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}},"404":{"description":"File not found","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/challenges/{id}/logs":{"get":{"security":[{"BearerAuth":[]}],"description":"Returns the logs of a challenge","tags":["challenges"],"summary":"Get challenge logs","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{"200":{"description":"Logs","schema":{"type":"string"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/challenges/{id}/publish":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Publish","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/start":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Start","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/status":{"get":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Status","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/stop":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Stop","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/solutions/{id}/download":{"get":{"description":"Downloads a solution","tags":["solutions"],"summary":"Download solution","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Token","name":"token","in":"query","required":true}],"responses":{"200":{"description":"Challenge file","schema":{"type":"file"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}},"404":{"description":"File not found","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/solutions/{id}/logs":{"get":{"security":[{"BearerAuth":[]}],"description":"Returns the logs of a solution","tags":["solutions"],"summary":"Get solution logs","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{"200":{"description":"Logs","schema":{"type":"string"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/solutions/{id}/start":{"post":{"security":[{"BearerAuth":[]}],"description":"Starts a test for a challenge","tags":["solutions"],"summary":"Start a test for a challenge","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TestResponse"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/solutions/{id}/stop":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["solutions"],"summary":"Solution Stop","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/solutions/{id}/verify":{"post":{"description":"Verifies the flag for a challenge","consumes":["application/json"],"produces":["application/json"],"tags":["solutions"],"summary":"Verify a challenge flag","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"description":"Flag request","name":"flag","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.FlagRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.FlagResponse"}},"400":{"description":"Bad Request","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/users/login":{"post":{"consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Login","parameters":[{"description":"Credentials","name":"login","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.LoginRequest"}}],"responses":{}}}},"definitions":{"handlers.ErrorResponse":{"type":"object","properties":{"error":{"type":"string"}}},"handlers.FlagRequest":{"type":"object","required":["flag"],"properties":{"flag":{"type":"string"}}},"handlers.FlagResponse":{"type":"object","properties":{"status":{"type":"string"}}},"handlers.LoginRequest":{"type":"object","properties":{"password":{"type":"string"},"username":{"type":"string"}}},"handlers.TestResponse":{"type":"object","properties":{"started":{"type":"boolean"},"verified":{"type":"boolean"}}}},"securityDefinitions":{"BearerAuth":{"description":"Type \"Bearer \u003cjwt-token\u003e\"","type":"apiKey","name":"Authorization","in":"header"}}}`
+    "schemes": {{ marshal .Schemes }},"swagger":"2.0","info":{"description":"{{escape .Description}}","title":"{{.Title}}","contact":{},"version":"{{.Version}}"},"host":"{{.Host}}","basePath":"{{.BasePath}}","paths":{"/apikeys":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists the API keys of the current user. Admins can list the keys of another user, or of all users with user=all.","produces":["application/json"],"tags":["apikeys"],"summary":"API Key List","parameters":[{"type":"string","description":"User ID","name":"user","in":"query"}],"responses":{}},"post":{"security":[{"BearerAuth":[]}],"description":"Creates an API key for the current local user, to be used like a token by scripts and CI jobs","consumes":["application/json"],"produces":["application/json"],"tags":["apikeys"],"summary":"API Key Create","parameters":[{"description":"API key","name":"key","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.CreateApiKeyRequest"}}],"responses":{"201":{"description":"Created","schema":{"$ref":"#/definitions/handlers.CreateApiKeyResponse"}}}}},"/apikeys/{id}":{"delete":{"security":[{"BearerAuth":[]}],"description":"Revokes an API key of the current user, or of any user for admins","produces":["application/json"],"tags":["apikeys"],"summary":"API Key Revoke","parameters":[{"type":"string","description":"API key ID","name":"id","in":"path","required":true}],"responses":{}}},"/auth/keys":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists the keys signing the tokens of local users, without their secrets. Without keys, tokens are signed with JWTSECRET.","produces":["application/json"],"tags":["auth"],"summary":"Signing Key List","responses":{"200":{"description":"OK","schema":{"type":"array","items":{"$ref":"#/definitions/handlers.SigningKeyResponse"}}}}}},"/auth/keys/rotate":{"post":{"security":[{"BearerAuth":[]}],"description":"Generates a new key to sign the tokens of local users. The previous key verifies the tokens it signed until they expire.","produces":["application/json"],"tags":["auth"],"summary":"Signing Key Rotate","responses":{"200":{"description":"OK","schema":{"type":"array","items":{"$ref":"#/definitions/handlers.SigningKeyResponse"}}}}}},"/auth/keys/{kid}":{"delete":{"security":[{"BearerAuth":[]}],"description":"Removes a retired signing key before its tokens expire, e.g. if it was leaked. The tokens it signed are rejected.","produces":["application/json"],"tags":["auth"],"summary":"Signing Key Delete","parameters":[{"type":"string","description":"Key ID","name":"kid","in":"path","required":true}],"responses":{}}},"/auth/login-failures":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists the latest failed logins of local users, newest first","produces":["application/json"],"tags":["auth"],"summary":"Login Failure List","parameters":[{"type":"string","description":"Username","name":"username","in":"query"},{"type":"integer","description":"Maximum number of failures, 100 by default","name":"limit","in":"query"}],"responses":{}}},"/backup/export":{"get":{"security":[{"BearerAuth":[]}],"description":"Streams a tar.gz archive with the users and their TOTP, every challenge with its flags, revisions, artifacts and publications, the hashes of the API keys, and the uploaded files","produces":["application/gzip"],"tags":["backup"],"summary":"Backup export","responses":{"200":{"description":"Backup archive","schema":{"type":"file"}}}}},"/backup/restore":{"post":{"security":[{"BearerAuth":[]}],"description":"Restores an archive from the export endpoint, usually on a fresh install. Users with an existing username are kept,\nchallenges with an existing ID are skipped, so restoring twice changes nothing. Other IDs are preserved unless they are taken.\nThe response lists the skipped challenges and maps the IDs that could not be preserved.","consumes":["multipart/form-data"],"produces":["application/json"],"tags":["backup"],"summary":"Backup restore","parameters":[{"type":"file","description":"Backup archive","name":"backup","in":"formData","required":true}],"responses":{}}},"/challenges":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists challenges with the metadata of their challenge.yml. Developers only see their own challenges.","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Callenges List","parameters":[{"type":"string","description":"Only challenges in this category","name":"category","in":"query"},{"type":"string","description":"Only challenges with this tag","name":"tag","in":"query"},{"type":"boolean","description":"Only published or unpublished challenges","name":"published","in":"query"},{"type":"boolean","description":"Only verified or unverified challenges","name":"verified","in":"query"},{"type":"string","description":"Text to find in name, category, author or tags","name":"search","in":"query"},{"type":"string","description":"name, category, author, value, state or created, prefixed with - for descending order","name":"sort","in":"query"},{"type":"integer","description":"Maximum number of challenges to return","name":"limit","in":"query"},{"type":"integer","description":"Number of challenges to skip","name":"offset","in":"query"}],"responses":{}},"post":{"security":[{"BearerAuth":[]}],"consumes":["multipart/form-data"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Add","parameters":[{"type":"array","items":{"type":"file"},"collectionFormat":"multi","description":"Allowed filenames: challenge.yml, challenge.zip, handout.zip, solution.zip","name":"upload[]","in":"formData","required":true}],"responses":{}}},"/challenges/import":{"post":{"security":[{"BearerAuth":[]}],"description":"Imports a tar or tar.gz archive of a ctfcli-style repository. Every directory with a challenge.yml is a challenge,\nits src, solution and handout directories are packed into challenge.zip, solution.zip and handout.zip.\nChallenges are matched by name: new ones are created, changed ones get a new revision.","consumes":["multipart/form-data"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Import","parameters":[{"type":"file","description":"Repository archive","name":"repository","in":"formData","required":true}],"responses":{}}},"/challenges/{id}":{"put":{"security":[{"BearerAuth":[]}],"consumes":["multipart/form-data"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Update","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"array","items":{"type":"file"},"collectionFormat":"multi","description":"Allowed filenames: challenge.yml, challenge.zip, handout.zip, solution.zip","name":"upload[]","in":"formData","required":true}],"responses":{}},"delete":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Delete","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/download":{"get":{"description":"Downloads the challenge.zip of the revision the instance was started with","tags":["challenges"],"summary":"Download challenge","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Token","name":"token","in":"query","required":true}],"responses":{"200":{"description":"Challenge file","schema":{"type":"file"},"headers":{"X-Checksum-Sha256":{"type":"string","description":"SHA-256 digest of the file"}}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}},"404":{"description":"File not found","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/challenges/{id}/hide":{"post":{"security":[{"BearerAuth":[]}],"description":"Hides a published challenge from players","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Hide","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Publish target, the default target if omitted","name":"target","in":"query"}],"responses":{}}},"/challenges/{id}/logs":{"get":{"security":[{"BearerAuth":[]}],"description":"Returns the logs of a challenge","tags":["challenges"],"summary":"Get challenge logs","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{"200":{"description":"Logs","schema":{"type":"string"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/challenges/{id}/promote":{"post":{"security":[{"BearerAuth":[]}],"description":"Publishes the revision published to one target, e.g. staging, to another, e.g. production. The revision must be the verified active revision.","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Promote","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Target the revision is published to","name":"from","in":"query","required":true},{"type":"string","description":"Target to publish to, the default target if omitted","name":"to","in":"query"}],"responses":{}}},"/challenges/{id}/publications":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists the targets the challenge is published to, with its ID and revision on each","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Publication List","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/publish":{"post":{"security":[{"BearerAuth":[]}],"description":"Publishes the active revision, or updates the challenge if it was published to the target before","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Publish","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Publish target, the default target if omitted","name":"target","in":"query"}],"responses":{}}},"/challenges/{id}/revisions":{"get":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Revision List","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/revisions/{revision}/download":{"get":{"security":[{"BearerAuth":[]}],"description":"Downloads a file of a challenge revision","tags":["challenges"],"summary":"Download challenge revision","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"integer","description":"Revision number","name":"revision","in":"path","required":true},{"type":"string","description":"File name: challenge.yml, challenge.zip, handout.zip or solution.zip","name":"file","in":"query","required":true}],"responses":{"200":{"description":"Revision file","schema":{"type":"file"},"headers":{"X-Checksum-Sha256":{"type":"string","description":"SHA-256 digest of the file"}}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}},"404":{"description":"File not found","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/challenges/{id}/revisions/{revision}/override":{"post":{"security":[{"BearerAuth":[]}],"description":"Allows a revision to be published even though its compose.yaml violates the security policy","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Revision Policy Override","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"integer","description":"Revision number","name":"revision","in":"path","required":true},{"description":"Override","name":"request","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.PolicyOverride"}}],"responses":{}}},"/challenges/{id}/revisions/{revision}/rollback":{"post":{"security":[{"BearerAuth":[]}],"description":"Makes a previous revision the active revision of the challenge","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Revision Rollback","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"integer","description":"Revision number","name":"revision","in":"path","required":true}],"responses":{}}},"/challenges/{id}/start":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Start","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/status":{"get":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Status","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/stop":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Stop","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/challenges/{id}/unhide":{"post":{"security":[{"BearerAuth":[]}],"description":"Makes a hidden challenge visible to players","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Unhide","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Publish target, the default target if omitted","name":"target","in":"query"}],"responses":{}}},"/challenges/{id}/unpublish":{"post":{"security":[{"BearerAuth":[]}],"description":"Removes the challenge from the platform of the target. Unpublishing from the default target also stops the running player instances. The challenge can be updated and published again afterwards.","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Challenge Unpublish","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Publish target, the default target if omitted","name":"target","in":"query"}],"responses":{}}},"/challenges/{id}/verify":{"post":{"security":[{"BearerAuth":[]}],"description":"Verifies a flag against the dynamic flags of the instances started by the current user","consumes":["application/json"],"produces":["application/json"],"tags":["challenges"],"summary":"Verify a player flag","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"description":"Flag request","name":"flag","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.FlagRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.FlagResponse"}},"400":{"description":"Bad Request","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/ctfd/drift":{"get":{"security":[{"BearerAuth":[]}],"description":"Reports differences between the published challenges and CTFd found by the latest reconciliation","consumes":["application/json"],"produces":["application/json"],"tags":["ctfd"],"summary":"CTFd drift","parameters":[{"type":"boolean","description":"Compare with CTFd now instead of returning the latest report","name":"refresh","in":"query"}],"responses":{}}},"/ctfd/drift/repair":{"post":{"security":[{"BearerAuth":[]}],"description":"Compares the published challenges with CTFd and repairs the database: challenges deleted in CTFd are unpublished with their player instances stopped, and states changed in CTFd are copied","consumes":["application/json"],"produces":["application/json"],"tags":["ctfd"],"summary":"CTFd drift repair","responses":{}}},"/solutions/{id}/download":{"get":{"description":"Downloads the solution.zip of the revision the test instance was started with","tags":["solutions"],"summary":"Download solution","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"type":"string","description":"Token","name":"token","in":"query","required":true}],"responses":{"200":{"description":"Challenge file","schema":{"type":"file"},"headers":{"X-Checksum-Sha256":{"type":"string","description":"SHA-256 digest of the file"}}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}},"404":{"description":"File not found","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/solutions/{id}/logs":{"get":{"security":[{"BearerAuth":[]}],"description":"Returns the logs of a solution","tags":["solutions"],"summary":"Get solution logs","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{"200":{"description":"Logs","schema":{"type":"string"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/solutions/{id}/start":{"post":{"security":[{"BearerAuth":[]}],"description":"Starts a test for a challenge","tags":["solutions"],"summary":"Start a test for a challenge","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TestResponse"}},"401":{"description":"Unauthorized","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/solutions/{id}/stop":{"post":{"security":[{"BearerAuth":[]}],"consumes":["application/json"],"produces":["application/json"],"tags":["solutions"],"summary":"Solution Stop","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true}],"responses":{}}},"/solutions/{id}/verify":{"post":{"description":"Verifies the flag for a challenge","consumes":["application/json"],"produces":["application/json"],"tags":["solutions"],"summary":"Verify a challenge flag","parameters":[{"type":"string","description":"Challenge ID","name":"id","in":"path","required":true},{"description":"Flag request","name":"flag","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.FlagRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.FlagResponse"}},"400":{"description":"Bad Request","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/submissions/shared":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists submissions of flags generated for the instance of another player","consumes":["application/json"],"produces":["application/json"],"tags":["submissions"],"summary":"Shared flag submissions","responses":{}}},"/users":{"get":{"security":[{"BearerAuth":[]}],"description":"Lists the local users","produces":["application/json"],"tags":["users"],"summary":"User List","responses":{}},"post":{"security":[{"BearerAuth":[]}],"description":"Creates a local user","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Create","parameters":[{"description":"User","name":"user","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.CreateUserRequest"}}],"responses":{}}},"/users/login":{"post":{"description":"Returns a short-lived access token and a refresh token, which gets new access tokens with /users/refresh.\nRepeated failures make further logins of the username or from the IP wait, up to a temporary lockout.\nUsers with TOTP get a login token instead, to complete the login with /users/login/totp.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Login","parameters":[{"description":"Credentials","name":"login","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.LoginRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TokenResponse"}},"401":{"description":"Invalid username or password","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}},"429":{"description":"Too many failed logins","schema":{"$ref":"#/definitions/handlers.ErrorResponse"}}}}},"/users/login/totp":{"post":{"description":"Completes a login with the login token and a TOTP or recovery code. If TOTP is required but not enrolled yet,\nthe code of the secret from /users/login/totp/setup enables it and the recovery codes are returned.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Login TOTP","parameters":[{"description":"Login token and code","name":"login","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.TotpLoginRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TotpLoginResponse"}}}}},"/users/login/totp/setup":{"post":{"description":"Starts the TOTP enrollment during a login of a user who has to use TOTP but has not enrolled yet","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Login TOTP Setup","parameters":[{"description":"Login token","name":"login","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.LoginTokenRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TotpSetupResponse"}}}}},"/users/logout":{"post":{"security":[{"BearerAuth":[]}],"description":"Ends the session of the access token: its refresh token and the access token itself are no longer accepted","produces":["application/json"],"tags":["users"],"summary":"User Logout","responses":{}}},"/users/me/password":{"put":{"security":[{"BearerAuth":[]}],"description":"Changes the password of the current local user. Existing tokens are rejected, so log in again afterwards.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Password Change","parameters":[{"description":"Passwords","name":"password","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.PasswordChangeRequest"}}],"responses":{}}},"/users/me/totp":{"post":{"security":[{"BearerAuth":[]}],"description":"Starts the TOTP enrollment of the current local user with a new secret. Enable it with /users/me/totp/enable.","produces":["application/json"],"tags":["users"],"summary":"User TOTP Setup","responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TotpSetupResponse"}}}},"delete":{"security":[{"BearerAuth":[]}],"description":"Disables TOTP for the current local user, unless it is required for the role","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User TOTP Disable","parameters":[{"description":"TOTP or recovery code","name":"code","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.TotpCodeRequest"}}],"responses":{}}},"/users/me/totp/enable":{"post":{"security":[{"BearerAuth":[]}],"description":"Enables TOTP for the current local user with a code of the secret from /users/me/totp, and returns the recovery codes.\nLogins need a TOTP code afterwards.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User TOTP Enable","parameters":[{"description":"TOTP code","name":"code","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.TotpCodeRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.RecoveryCodesResponse"}}}}},"/users/me/totp/recovery-codes":{"post":{"security":[{"BearerAuth":[]}],"description":"Replaces the recovery codes of the current local user, e.g. when most are used","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User TOTP Recovery Codes","parameters":[{"description":"TOTP or recovery code","name":"code","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.TotpCodeRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.RecoveryCodesResponse"}}}}},"/users/refresh":{"post":{"description":"Returns a new access token for the session of the refresh token. The refresh token is replaced by the one returned;\nusing a replaced refresh token again revokes the session. The session expires at the time set at login.\nSessions of users whose role requires TOTP are revoked until they have enrolled.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Token Refresh","parameters":[{"description":"Refresh token","name":"refresh","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.RefreshRequest"}}],"responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/handlers.TokenResponse"}}}}},"/users/{id}":{"delete":{"security":[{"BearerAuth":[]}],"description":"Deletes a local user. Challenges of the user are kept.","produces":["application/json"],"tags":["users"],"summary":"User Delete","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true}],"responses":{}}},"/users/{id}/disable":{"post":{"security":[{"BearerAuth":[]}],"description":"Disables a local user. The user cannot log in and existing tokens are rejected.","produces":["application/json"],"tags":["users"],"summary":"User Disable","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true}],"responses":{}}},"/users/{id}/enable":{"post":{"security":[{"BearerAuth":[]}],"description":"Enables a disabled local user","produces":["application/json"],"tags":["users"],"summary":"User Enable","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true}],"responses":{}}},"/users/{id}/password":{"post":{"security":[{"BearerAuth":[]}],"description":"Sets a new password for a local user. Existing tokens of the user are rejected.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Password Reset","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true},{"description":"New password","name":"password","in":"body","schema":{"$ref":"#/definitions/handlers.PasswordResetRequest"}}],"responses":{}}},"/users/{id}/role":{"put":{"security":[{"BearerAuth":[]}],"description":"Changes the role of a local user. The user has to log in again.","consumes":["application/json"],"produces":["application/json"],"tags":["users"],"summary":"User Role","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true},{"description":"Role","name":"role","in":"body","required":true,"schema":{"$ref":"#/definitions/handlers.UserRoleRequest"}}],"responses":{}}},"/users/{id}/sessions/revoke":{"post":{"security":[{"BearerAuth":[]}],"description":"Logs a local user out everywhere, e.g. after a lost laptop. Refresh tokens and access tokens issued before are rejected.","produces":["application/json"],"tags":["users"],"summary":"User Sessions Revoke","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true}],"responses":{}}},"/users/{id}/totp":{"delete":{"security":[{"BearerAuth":[]}],"description":"Disables TOTP for a local user who lost the authenticator app and the recovery codes.\nIf TOTP is required for the role, the user enrolls again at the next login.","produces":["application/json"],"tags":["users"],"summary":"User TOTP Reset","parameters":[{"type":"string","description":"User ID","name":"id","in":"path","required":true}],"responses":{}}}},"definitions":{"handlers.CreateApiKeyRequest":{"type":"object","properties":{"expires_in_days":{"description":"The key does not expire if omitted","type":"integer"},"name":{"type":"string"},"scopes":{"description":"read, write and admin","type":"array","items":{"type":"string"}}}},"handlers.CreateApiKeyResponse":{"type":"object","properties":{"created_at":{"type":"string"},"expires_at":{"type":"string"},"id":{"type":"string"},"key":{"description":"Only returned once, it cannot be retrieved later","type":"string"},"last_used_at":{"type":"string"},"name":{"type":"string"},"prefix":{"description":"First characters of the key, to recognize it","type":"string"},"revoked_at":{"type":"string"},"scopes":{"type":"array","items":{"type":"string"}},"user_id":{"type":"string"}}},"handlers.CreateUserRequest":{"type":"object","properties":{"password":{"type":"string"},"role":{"description":"admin or developer","type":"string"},"username":{"type":"string"}}},"handlers.ErrorResponse":{"type":"object","properties":{"error":{"type":"string"}}},"handlers.FlagRequest":{"type":"object","required":["flag"],"properties":{"flag":{"type":"string"}}},"handlers.FlagResponse":{"type":"object","properties":{"status":{"type":"string"}}},"handlers.LoginRequest":{"type":"object","properties":{"password":{"type":"string"},"username":{"type":"string"}}},"handlers.LoginTokenRequest":{"type":"object","required":["login_token"],"properties":{"login_token":{"type":"string"}}},"handlers.PasswordChangeRequest":{"type":"object","properties":{"current_password":{"type":"string"},"new_password":{"type":"string"}}},"handlers.PasswordResetRequest":{"type":"object","properties":{"password":{"description":"A random password is generated and returned if omitted","type":"string"}}},"handlers.PolicyOverride":{"type":"object","properties":{"override":{"description":"False withdraws a previous override","type":"boolean"}}},"handlers.RecoveryCodesResponse":{"type":"object","properties":{"recovery_codes":{"description":"Each code replaces a TOTP code once. They are only shown now.","type":"array","items":{"type":"string"}}}},"handlers.RefreshRequest":{"type":"object","required":["refresh_token"],"properties":{"refresh_token":{"type":"string"}}},"handlers.SigningKeyResponse":{"type":"object","properties":{"active":{"description":"Whether the key signs new tokens","type":"boolean"},"created_at":{"type":"string"},"kid":{"type":"string"},"retired_at":{"type":"string"},"valid_until":{"description":"When the last token signed by a retired key expires and the key is removed","type":"string"}}},"handlers.TestResponse":{"type":"object","properties":{"started":{"type":"boolean"},"verified":{"type":"boolean"}}},"handlers.TokenResponse":{"type":"object","properties":{"expires_in":{"description":"Seconds until the token expires","type":"integer"},"refresh_token":{"type":"string"},"token":{"type":"string"}}},"handlers.TotpCodeRequest":{"type":"object","properties":{"code":{"type":"string"},"recovery_code":{"type":"string"}}},"handlers.TotpLoginRequest":{"type":"object","required":["login_token"],"properties":{"code":{"type":"string"},"login_token":{"type":"string"},"recovery_code":{"type":"string"}}},"handlers.TotpLoginResponse":{"type":"object","properties":{"expires_in":{"description":"Seconds until the token expires","type":"integer"},"recovery_codes":{"type":"array","items":{"type":"string"}},"refresh_token":{"type":"string"},"token":{"type":"string"}}},"handlers.TotpSetupResponse":{"type":"object","properties":{"provisioning_uri":{"description":"otpauth:// URI to show as QR code to the authenticator app","type":"string"},"secret":{"type":"string"}}},"handlers.UserRoleRequest":{"type":"object","properties":{"role":{"description":"admin or developer","type":"string"}}}},"securityDefinitions":{"BearerAuth":{"description":"Type \"Bearer \u003cjwt-token\u003e\" or \"Bearer \u003capi-key\u003e\"","type":"apiKey","name":"Authorization","in":"header"}}}`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApiKeyPrefix starts every API key, so they can be told apart from JWTs
+const ApiKeyPrefix = "dpl_"
+
+// RefreshTokenPrefix starts every refresh token
+const RefreshTokenPrefix = "dpr_"
+
+const (
+	// Allows GET requests
+	ScopeRead = "read"
+	// Allows requests that change something
+	ScopeWrite = "write"
+	// Allows the key to act as admin if its owner is an admin, otherwise it acts as developer
+	ScopeAdmin = "admin"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+const ContextApiKeyIdKey = "apikeyid"
+
+// ApiKey is a valid API key and the user it belongs to
+type ApiKey struct {
+	Id     string
+	UserId string
+	// Current role of the owner
+	Role   string
+	Scopes []string
+}
+
+// FindApiKey returns the API key with the hash if it has not expired or been revoked and its owner is not disabled.
+// It is set by the server, as the keys are stored outside this package.
+var FindApiKey func(hash string) (ApiKey, error)
+
+// NewApiKey generates a random key. Only the hash is stored, the key is shown to the user once.
+func NewApiKey() (string, string, error) {
+	return newSecretToken(ApiKeyPrefix)
+}
+
+// NewRefreshToken generates the token that gets new access tokens for a session. Only the hash is stored.
+func NewRefreshToken() (string, string, error) {
+	return newSecretToken(RefreshTokenPrefix)
+}
+
+// newSecretToken generates a random token and its hash
+func newSecretToken(prefix string) (string, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	token := prefix + base64.RawURLEncoding.EncodeToString(random)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the SHA-256 digest of an API key or refresh token. They are random, so they do not need a slow password hash.
+func HashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// role is the role the key acts as, which is never more than its owner has
+func (k ApiKey) role() string {
+	if k.Role == AdminRoleKey && !slices.Contains(k.Scopes, ScopeAdmin) {
+		return DeveloperRoleKey
+	}
+	return k.Role
+}
+
+func (k ApiKey) allows(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(k.Scopes, ScopeRead)
+	default:
+		return slices.Contains(k.Scopes, ScopeWrite)
+	}
+}
+
+func requireApiKey(c *gin.Context, key string, allowedRoles []string) bool {
+	if FindApiKey == nil {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return false
+	}
+	apiKey, err := FindApiKey(HashToken(key))
+	if err != nil {
+		log.Println("Invalid API key")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return false
+	}
+
+	if !apiKey.allows(c.Request.Method) {
+		log.Println("Missing API key scope")
+		c.AbortWithStatus(http.StatusForbidden)
+		return false
+	}
+	role := apiKey.role()
+	if len(allowedRoles) != 0 && !slices.Contains(allowedRoles, role) {
+		log.Println("Missing role")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return false
+	}
+
+	c.Set(ContextUserIdKey, apiKey.UserId)
+	c.Set(ContextRoleKey, role)
+	c.Set(ContextApiKeyIdKey, apiKey.Id)
+	log.Println("Setting context for userid with API key for: " + apiKey.UserId)
+	return true
+}
+
+// IsApiKey reports whether the request was authenticated with an API key
+func IsApiKey(c *gin.Context) bool {
+	return c.GetString(ContextApiKeyIdKey) != ""
+}
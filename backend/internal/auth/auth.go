@@ -1,16 +1,15 @@
 package auth
 
 import (
-	"deployer/config"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"slices"
 	"strings"
 	"time"
 
-	"github.com/MicahParks/keyfunc/v3"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
@@ -19,15 +18,8 @@ import (
 type Claims struct {
 	UserId string
 	Role   string
-	jwt.RegisteredClaims
-}
-
-type KeycloakClaims struct {
-	ResourceAccess struct {
-		Deployer struct {
-			Roles []string `json:"roles"`
-		} `json:"deployer"`
-	} `json:"resource_access"`
+	// Session of the refresh token the token was issued with
+	SessionId string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -37,10 +29,21 @@ const (
 )
 
 const (
-	ContextUserIdKey = "userid"
-	ContextRoleKey   = "role"
+	ContextUserIdKey      = "userid"
+	ContextRoleKey        = "role"
+	ContextTokenIdKey     = "jti"
+	ContextSessionIdKey   = "sid"
+	ContextTokenExpiryKey = "exp"
 )
 
+// Roles that can be given to local users
+var Roles = []string{AdminRoleKey, DeveloperRoleKey}
+
+// TokenValid reports whether a local user may still use a token issued at the given time: the user is not disabled,
+// the token with the jti was not revoked, e.g. by logging out, and neither was its session, e.g. by an admin or a reused refresh token.
+// It is set by the server, as the users, revocations and sessions are stored outside this package.
+var TokenValid func(userId string, issuedAt time.Time, jti, sessionId string) (bool, error)
+
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -49,17 +52,25 @@ func HashPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
-func CreateToken(userId, role string) string {
-	expirationTime := time.Now().Add(time.Hour * 24)
+// CreateToken issues a short-lived access token for the session
+func CreateToken(userId, role, sessionId string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	expirationTime := now.Add(AccessTokenLifetime())
 	claims := &Claims{
-		UserId: userId,
-		Role:   role,
+		UserId:    userId,
+		Role:      role,
+		SessionId: sessionId,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(config.Values.JwtSecret)
-	return token
+	return signToken(claims)
 }
 
 func RequireAuth(c *gin.Context) {
@@ -83,15 +94,28 @@ func RequireRole(c *gin.Context, allowedRoles []string) {
 		return
 	}
 
-	if len(config.Values.JwksUrl) > 0 {
-		k, err := keyfunc.NewDefaultCtx(c, []string{config.Values.JwksUrl})
+	if strings.HasPrefix(parts[1], ApiKeyPrefix) {
+		if requireApiKey(c, parts[1], allowedRoles) {
+			c.Next()
+		}
+		return
+	}
+
+	if externalTokens() {
+		url, options, err := identityProvider(c)
+		if err != nil {
+			log.Println("Failed to discover identity provider: " + err.Error())
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		k, err := providerKeyfunc(url)
 		if err != nil {
 			log.Println("Failed to create keyfunc" + err.Error())
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-		claims := &KeycloakClaims{}
-		_, err = jwt.ParseWithClaims(parts[1], claims, k.Keyfunc)
+		claims := jwt.MapClaims{}
+		_, err = jwt.ParseWithClaims(parts[1], claims, k.Keyfunc, options...)
 
 		if err != nil {
 			if errors.Is(err, jwt.ErrSignatureInvalid) {
@@ -99,40 +123,28 @@ func RequireRole(c *gin.Context, allowedRoles []string) {
 				c.AbortWithStatus(http.StatusUnauthorized)
 				return
 			}
-			log.Println("Expired token error")
+			log.Println("Invalid token error: " + err.Error())
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
-		var intersect []string
-		for _, role := range claims.ResourceAccess.Deployer.Roles {
-			if slices.Contains(allowedRoles, role) {
-				intersect = append(intersect, role)
-			}
-		}
-
-		if len(allowedRoles) != 0 && len(intersect) == 0 {
+		role := mapRole(claims)
+		if len(allowedRoles) != 0 && !slices.Contains(allowedRoles, role) {
 			log.Println("Missing role")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
-		c.Set(ContextUserIdKey, claims.Subject)
-		if slices.Contains(claims.ResourceAccess.Deployer.Roles, AdminRoleKey) {
-			c.Set(ContextRoleKey, AdminRoleKey)
-		} else if slices.Contains(claims.ResourceAccess.Deployer.Roles, DeveloperRoleKey) {
-			c.Set(ContextRoleKey, DeveloperRoleKey)
+		subject, _ := claims.GetSubject()
+		c.Set(ContextUserIdKey, subject)
+		if role != "" {
+			c.Set(ContextRoleKey, role)
 		}
 
-		log.Println("Setting context for userid for: " + claims.Subject)
+		log.Println("Setting context for userid for: " + subject)
 	} else {
 		claims := &Claims{}
-		_, err := jwt.ParseWithClaims(parts[1], claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("signing method invalid: %v", token.Header["alg"])
-			}
-			return config.Values.JwtSecret, nil
-		})
+		_, err := jwt.ParseWithClaims(parts[1], claims, verificationKey)
 
 		if err != nil {
 			if errors.Is(err, jwt.ErrSignatureInvalid) {
@@ -151,8 +163,26 @@ func RequireRole(c *gin.Context, allowedRoles []string) {
 			return
 		}
 
+		if TokenValid != nil {
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			valid, err := TokenValid(claims.UserId, issuedAt, claims.ID, claims.SessionId)
+			if err != nil || !valid {
+				log.Println("User disabled, token or session revoked")
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+
 		c.Set(ContextUserIdKey, claims.UserId)
 		c.Set(ContextRoleKey, claims.Role)
+		c.Set(ContextTokenIdKey, claims.ID)
+		c.Set(ContextSessionIdKey, claims.SessionId)
+		if claims.ExpiresAt != nil {
+			c.Set(ContextTokenExpiryKey, claims.ExpiresAt.Time)
+		}
 		log.Println("Setting context for userid for: " + claims.UserId)
 	}
 	c.Next()
@@ -165,3 +195,8 @@ func GetCurrentUserId(c *gin.Context) string {
 func IsAdmin(c *gin.Context) bool {
 	return c.GetString(ContextRoleKey) == AdminRoleKey
 }
+
+// GetCurrentSession returns the session, jti and expiry of the access token of a local user, empty for other tokens
+func GetCurrentSession(c *gin.Context) (string, string, time.Time) {
+	return c.GetString(ContextSessionIdKey), c.GetString(ContextTokenIdKey), c.GetTime(ContextTokenExpiryKey)
+}
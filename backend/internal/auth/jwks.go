@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"deployer/config"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/jwkset"
+	"github.com/MicahParks/keyfunc/v3"
+	"golang.org/x/time/rate"
+)
+
+// Tokens signed with an unknown kid refresh the JWK Set, but not more often than this
+const unknownKidRefreshInterval = 5 * time.Minute
+
+var jwks struct {
+	sync.Mutex
+	url     string
+	keyfunc keyfunc.Keyfunc
+	cancel  context.CancelFunc
+}
+
+// providerKeyfunc returns the keys of the identity provider at the URL. The JWK Set is fetched once per process
+// and refreshed in the background, so requests do not wait for the identity provider.
+func providerKeyfunc(jwksUrl string) (keyfunc.Keyfunc, error) {
+	jwks.Lock()
+	defer jwks.Unlock()
+	if jwks.keyfunc != nil && jwks.url == jwksUrl {
+		return jwks.keyfunc, nil
+	}
+
+	parsed, err := url.ParseRequestURI(jwksUrl)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	storage, err := jwkset.NewStorageFromHTTP(parsed, jwkset.HTTPClientStorageOptions{
+		Ctx:                       ctx,
+		NoErrorReturnFirstHTTPReq: true,
+		RefreshErrorHandler: func(ctx context.Context, err error) {
+			log.Printf("Failed to refresh JWKS from %s: %v", jwksUrl, err)
+		},
+		RefreshInterval: time.Duration(config.Values.Oidc.JwksRefreshMinutes) * time.Minute,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	client, err := jwkset.NewHTTPClient(jwkset.HTTPClientOptions{
+		HTTPURLs:          map[string]jwkset.Storage{parsed.String(): storage},
+		RateLimitWaitMax:  time.Minute,
+		RefreshUnknownKID: rate.NewLimiter(rate.Every(unknownKidRefreshInterval), 1),
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	k, err := keyfunc.New(keyfunc.Options{Storage: client})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Stop refreshing the keys of a previous URL
+	if jwks.cancel != nil {
+		jwks.cancel()
+	}
+	jwks.url, jwks.keyfunc, jwks.cancel = jwksUrl, k, cancel
+	return k, nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"deployer/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+const (
+	// Requests wait for the discovery while holding its lock, so it gives up after this
+	discoveryTimeout = 10 * time.Second
+	// After a failed discovery, requests fail with its error for this long instead of asking the identity provider again
+	discoveryRetryInterval = 30 * time.Second
+)
+
+var discoveryClient = &http.Client{Timeout: discoveryTimeout}
+
+// discoveryDocument is the part of the OpenID Provider Metadata the deployer needs
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JwksUri string `json:"jwks_uri"`
+}
+
+var discovery struct {
+	sync.Mutex
+	document *discoveryDocument
+	// Error of the last failed discovery, returned until retryAt
+	err     error
+	retryAt time.Time
+}
+
+// discover fetches the OpenID configuration of the issuer. It is kept once fetched, a failure is retried after discoveryRetryInterval.
+func discover(ctx context.Context, issuer string) (discoveryDocument, error) {
+	discovery.Lock()
+	defer discovery.Unlock()
+	if discovery.document != nil {
+		return *discovery.document, nil
+	}
+	if discovery.err != nil && time.Now().Before(discovery.retryAt) {
+		return discoveryDocument{}, discovery.err
+	}
+
+	document, err := fetchDiscovery(ctx, issuer)
+	if err != nil {
+		discovery.err, discovery.retryAt = err, time.Now().Add(discoveryRetryInterval)
+		return document, err
+	}
+	discovery.document, discovery.err = &document, nil
+	return document, nil
+}
+
+func fetchDiscovery(ctx context.Context, issuer string) (discoveryDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return discoveryDocument{}, err
+	}
+	resp, err := discoveryClient.Do(req)
+	if err != nil {
+		return discoveryDocument{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return discoveryDocument{}, fmt.Errorf("OpenID configuration of %s returned %s", issuer, resp.Status)
+	}
+
+	var document discoveryDocument
+	err = json.NewDecoder(resp.Body).Decode(&document)
+	if err != nil {
+		return document, err
+	}
+	// The issuer in the document must be identical to the configured one, which is then checked in every token
+	if document.Issuer != issuer {
+		return document, fmt.Errorf("OpenID configuration of %s has issuer %s", issuer, document.Issuer)
+	}
+	if document.JwksUri == "" {
+		return document, fmt.Errorf("OpenID configuration of %s has no jwks_uri", issuer)
+	}
+	return document, nil
+}
+
+// externalTokens reports whether tokens are issued by an identity provider instead of the deployer
+func externalTokens() bool {
+	return len(config.Values.Oidc.Issuer) > 0 || len(config.Values.JwksUrl) > 0
+}
+
+// identityProvider returns where the keys of the identity provider are found, and the options to validate its tokens
+func identityProvider(ctx context.Context) (string, []jwt.ParserOption, error) {
+	var options []jwt.ParserOption
+	if config.Values.Oidc.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Values.Oidc.Audience))
+	}
+	if config.Values.Oidc.Issuer == "" {
+		return config.Values.JwksUrl, options, nil
+	}
+
+	document, err := discover(ctx, config.Values.Oidc.Issuer)
+	if err != nil {
+		return "", nil, err
+	}
+	return document.JwksUri, append(options, jwt.WithIssuer(document.Issuer)), nil
+}
+
+// mapRole returns the highest role the claims give: admin, developer, or an empty string for players
+func mapRole(claims jwt.MapClaims) string {
+	mapping := config.Values.Oidc.RoleMapping
+
+	var roles []string
+	for _, role := range claimValues(claims, config.Values.Oidc.RolesClaim) {
+		if mapped, ok := mapping[role]; ok {
+			role = mapped
+		}
+		roles = append(roles, role)
+	}
+	for _, group := range claimValues(claims, config.Values.Oidc.GroupsClaim) {
+		if mapped, ok := mapping[group]; ok {
+			roles = append(roles, mapped)
+		}
+	}
+
+	if slices.Contains(roles, AdminRoleKey) {
+		return AdminRoleKey
+	}
+	if slices.Contains(roles, DeveloperRoleKey) {
+		return DeveloperRoleKey
+	}
+	return ""
+}
+
+// claimValues returns the strings at the dot separated path, which is a list or a single string
+func claimValues(claims jwt.MapClaims, path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var value any = map[string]any(claims)
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var result []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
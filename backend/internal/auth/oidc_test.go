@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"deployer/config"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const stubKid = "stub-key"
+
+// stubProvider is an identity provider serving its OpenID configuration and a JWK Set with one RSA key
+type stubProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// Issuer written into the OpenID configuration, the server URL if empty
+	issuer string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+	provider := &stubProvider{key: newRsaKey(t)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		issuer := provider.issuer
+		if issuer == "" {
+			issuer = provider.URL
+		}
+		json.NewEncoder(w).Encode(discoveryDocument{Issuer: issuer, JwksUri: provider.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		encode := func(value []byte) string { return base64.RawURLEncoding.EncodeToString(value) }
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": stubKid,
+			"n":   encode(provider.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(provider.key.E)).Bytes()),
+		}}})
+	})
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func newRsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// token signs the claims with the key of the provider, adding its issuer and a validity of a few minutes unless given
+func (p *stubProvider) token(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	return signRsa(t, p.key, p.withDefaults(claims))
+}
+
+func (p *stubProvider) withDefaults(claims jwt.MapClaims) jwt.MapClaims {
+	result := jwt.MapClaims{
+		"iss": p.URL,
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for key, value := range claims {
+		result[key] = value
+	}
+	return result
+}
+
+func signRsa(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = stubKid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// useOidc configures the provider as issuer and forgets the discovery and keys of previous tests
+func useOidc(t *testing.T, oidc config.OidcConfig) {
+	t.Helper()
+	previous, previousJwksUrl := config.Values.Oidc, config.Values.JwksUrl
+	config.Values.Oidc = oidc
+	config.Values.JwksUrl = ""
+	resetProvider()
+	t.Cleanup(func() {
+		config.Values.Oidc, config.Values.JwksUrl = previous, previousJwksUrl
+		resetProvider()
+	})
+}
+
+func resetProvider() {
+	discovery.Lock()
+	discovery.document, discovery.err, discovery.retryAt = nil, nil, time.Time{}
+	discovery.Unlock()
+
+	jwks.Lock()
+	if jwks.cancel != nil {
+		jwks.cancel()
+	}
+	jwks.url, jwks.keyfunc, jwks.cancel = "", nil, nil
+	jwks.Unlock()
+}
+
+func TestDiscover(t *testing.T) {
+	provider := newStubProvider(t)
+	useOidc(t, config.OidcConfig{Issuer: provider.URL})
+
+	document, err := discover(context.Background(), provider.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.Issuer != provider.URL || document.JwksUri != provider.URL+"/jwks" {
+		t.Errorf("document = %+v", document)
+	}
+
+	// The document is kept, so a provider that is down later does not matter
+	provider.Close()
+	if _, err := discover(context.Background(), provider.URL); err != nil {
+		t.Errorf("discover after the first success: %v", err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	provider := newStubProvider(t)
+	provider.issuer = "https://other.example.com"
+	useOidc(t, config.OidcConfig{Issuer: provider.URL})
+
+	_, err := discover(context.Background(), provider.URL)
+	if err == nil || !strings.Contains(err.Error(), "has issuer https://other.example.com") {
+		t.Fatalf("error = %v, want an issuer mismatch", err)
+	}
+	if discovery.document != nil {
+		t.Error("a document with the wrong issuer was kept")
+	}
+
+	// Until the retry interval has passed, the failure is returned without asking the provider again
+	provider.issuer = ""
+	if _, err := discover(context.Background(), provider.URL); err == nil || !strings.Contains(err.Error(), "has issuer https://other.example.com") {
+		t.Errorf("discover right after a failure: %v, want the previous error", err)
+	}
+
+	discovery.retryAt = time.Now()
+	if _, err := discover(context.Background(), provider.URL); err != nil {
+		t.Errorf("discover after the retry interval: %v", err)
+	}
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	noJwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{Issuer: "http://" + r.Host})
+	}))
+	defer noJwks.Close()
+
+	tests := []struct {
+		name   string
+		issuer string
+		want   string
+	}{
+		{"not found", missing.URL, "404"},
+		{"no jwks_uri", noJwks.URL, "has no jwks_uri"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useOidc(t, config.OidcConfig{Issuer: test.issuer})
+			_, err := discover(context.Background(), test.issuer)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestClaimValues(t *testing.T) {
+	claims := jwt.MapClaims{
+		"groups": []any{"ctf-admins", 42, "ctf-authors"},
+		"role":   "developer",
+		"resource_access": map[string]any{
+			"deployer": map[string]any{"roles": []any{"admin"}},
+			"other":    "not an object",
+		},
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"", nil},
+		{"groups", []string{"ctf-admins", "ctf-authors"}},
+		{"role", []string{"developer"}},
+		{"resource_access.deployer.roles", []string{"admin"}},
+		{"resource_access.other.roles", nil},
+		{"resource_access.missing.roles", nil},
+		{"role.nested", nil},
+		{"resource_access", nil},
+	}
+	for _, test := range tests {
+		if got := claimValues(claims, test.path); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("claimValues(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	useOidc(t, config.OidcConfig{
+		RolesClaim:  "resource_access.deployer.roles",
+		GroupsClaim: "groups",
+		RoleMapping: config.RoleMapping{"ctf-admins": "admin", "ctf-authors": "developer", "deployer-admin": "developer"},
+	})
+
+	roles := func(roles ...any) map[string]any {
+		return map[string]any{"deployer": map[string]any{"roles": roles}}
+	}
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   string
+	}{
+		{"no claims", jwt.MapClaims{}, ""},
+		{"unmapped admin role", jwt.MapClaims{"resource_access": roles("admin")}, AdminRoleKey},
+		{"unmapped developer role", jwt.MapClaims{"resource_access": roles("developer")}, DeveloperRoleKey},
+		{"unknown role", jwt.MapClaims{"resource_access": roles("viewer")}, ""},
+		{"mapped role", jwt.MapClaims{"resource_access": roles("ctf-admins")}, AdminRoleKey},
+		{"mapping overrides the role name", jwt.MapClaims{"resource_access": roles("deployer-admin")}, DeveloperRoleKey},
+		{"mapped group", jwt.MapClaims{"groups": []any{"ctf-authors"}}, DeveloperRoleKey},
+		{"group named like a role", jwt.MapClaims{"groups": []any{"admin"}}, ""},
+		{"highest role wins", jwt.MapClaims{"resource_access": roles("developer"), "groups": []any{"ctf-admins"}}, AdminRoleKey},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := mapRole(test.claims); got != test.want {
+				t.Errorf("mapRole = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// serveWithRole runs the middleware for the roles and returns the status and the user it found
+func serveWithRole(t *testing.T, token string, allowedRoles ...string) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) { RequireRole(c, allowedRoles) }, func(c *gin.Context) {
+		c.String(http.StatusOK, GetCurrentUserId(c)+" "+c.GetString(ContextRoleKey))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+func TestRequireRoleWithProviderToken(t *testing.T) {
+	provider := newStubProvider(t)
+	useOidc(t, config.OidcConfig{
+		Issuer:             provider.URL,
+		Audience:           "deployer",
+		RolesClaim:         "roles",
+		RoleMapping:        config.RoleMapping{"ctf-authors": "developer"},
+		JwksRefreshMinutes: 60,
+	})
+
+	developer := jwt.MapClaims{"aud": "deployer", "roles": []any{"ctf-authors"}}
+	code, body := serveWithRole(t, provider.token(t, developer), DeveloperRoleKey)
+	if code != http.StatusOK || body != "user-1 developer" {
+		t.Fatalf("developer token: %d %q", code, body)
+	}
+
+	code, body = serveWithRole(t, provider.token(t, jwt.MapClaims{"aud": "deployer"}))
+	if code != http.StatusOK || body != "user-1 " {
+		t.Errorf("player token without required role: %d %q", code, body)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		roles []string
+	}{
+		{"missing role", provider.token(t, developer), []string{AdminRoleKey}},
+		{"wrong audience", provider.token(t, jwt.MapClaims{"aud": "other", "roles": []any{"ctf-authors"}}), nil},
+		{"no audience", provider.token(t, jwt.MapClaims{"roles": []any{"ctf-authors"}}), nil},
+		{"wrong issuer", provider.token(t, jwt.MapClaims{"aud": "deployer", "iss": "https://other.example.com"}), nil},
+		{"expired", provider.token(t, jwt.MapClaims{"aud": "deployer", "exp": time.Now().Add(-time.Minute).Unix()}), nil},
+		{"signed by another key", signRsa(t, newRsaKey(t), provider.withDefaults(developer)), nil},
+		{"not a token", "garbage", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _ := serveWithRole(t, test.token, test.roles...)
+			if code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", code)
+			}
+		})
+	}
+}
+
+func TestRequireRoleIssuerMismatch(t *testing.T) {
+	provider := newStubProvider(t)
+	provider.issuer = "https://other.example.com"
+	useOidc(t, config.OidcConfig{Issuer: provider.URL, RolesClaim: "roles"})
+
+	code, _ := serveWithRole(t, provider.token(t, jwt.MapClaims{"roles": []any{"admin"}}))
+	if code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401 when the provider reports another issuer", code)
+	}
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto/rand"
+	"deployer/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessTokenLifetime is how long access tokens of local users are valid
+func AccessTokenLifetime() time.Duration {
+	return time.Duration(config.Values.Tokens.AccessMinutes) * time.Minute
+}
+
+// RefreshTokenLifetime is how long a session lasts after logging in
+func RefreshTokenLifetime() time.Duration {
+	return time.Duration(config.Values.Tokens.RefreshDays) * 24 * time.Hour
+}
+
+// Signing keys are read again after this time, so rotations on other replicas are noticed
+const signingKeysCacheTime = time.Minute
+
+// Tokens with an unknown kid read the keys again, but not more often than this
+const unknownKidReloadInterval = time.Second
+
+var ErrUnknownKid = errors.New("token signed with an unknown key")
+
+// JwtSecretKid stands for JWTSECRET, which signs tokens without kid until the first rotation.
+// The first rotation retires it like any other key, its secret is not stored.
+const JwtSecretKid = "jwtsecret"
+
+// SigningKey signs the tokens of local users. The newest key signs, retired keys still verify
+// the tokens they signed until these expire.
+type SigningKey struct {
+	Kid       string     `json:"kid"`
+	Secret    []byte     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at"`
+}
+
+// Active reports whether the key signs new tokens
+func (k SigningKey) Active() bool {
+	return k.RetiredAt == nil
+}
+
+// ValidUntil is when the last token signed by a retired key expires, nil for the active key
+func (k SigningKey) ValidUntil() *time.Time {
+	if k.RetiredAt == nil {
+		return nil
+	}
+	until := k.RetiredAt.Add(AccessTokenLifetime())
+	return &until
+}
+
+// LoadSigningKeys returns the signing keys, newest first. Without keys, tokens are signed with JWTSECRET and have no kid.
+// It is set by the server, as the keys are stored outside this package.
+var LoadSigningKeys func() ([]SigningKey, error)
+
+var signingKeys struct {
+	sync.Mutex
+	keys   []SigningKey
+	loaded time.Time
+	// Last time the keys were read again for an unknown kid
+	reloadedForKid time.Time
+}
+
+// NewSigningKey generates a key with a random kid and secret
+func NewSigningKey() (SigningKey, error) {
+	kid := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(kid); err != nil {
+		return SigningKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{Kid: hex.EncodeToString(kid), Secret: secret, CreatedAt: time.Now()}, nil
+}
+
+// ReloadSigningKeys forgets the cached keys, e.g. after a rotation
+func ReloadSigningKeys() {
+	signingKeys.Lock()
+	defer signingKeys.Unlock()
+	signingKeys.loaded = time.Time{}
+}
+
+// currentSigningKeys returns the keys that sign or verify tokens, newest first
+func currentSigningKeys() ([]SigningKey, error) {
+	if LoadSigningKeys == nil {
+		return nil, nil
+	}
+
+	signingKeys.Lock()
+	defer signingKeys.Unlock()
+	if time.Since(signingKeys.loaded) < signingKeysCacheTime {
+		return signingKeys.keys, nil
+	}
+
+	keys, err := LoadSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	signingKeys.keys = nil
+	for _, key := range keys {
+		if until := key.ValidUntil(); until == nil || until.After(time.Now()) {
+			signingKeys.keys = append(signingKeys.keys, key)
+		}
+	}
+	signingKeys.loaded = time.Now()
+	return signingKeys.keys, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	keys, err := currentSigningKeys()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	for _, key := range keys {
+		if key.Active() {
+			token.Header["kid"] = key.Kid
+			return token.SignedString(key.Secret)
+		}
+	}
+	return token.SignedString(config.Values.JwtSecret)
+}
+
+// verificationKey is the jwt.Keyfunc of local tokens
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("signing method invalid: %v", token.Header["alg"])
+	}
+	kid, hasKid := token.Header["kid"].(string)
+
+	keys, err := currentSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	secret, ok := secretFor(keys, kid, hasKid)
+	// The key may have been rotated on another replica since the keys were read
+	if !ok && reloadForUnknownKid() {
+		keys, err = currentSigningKeys()
+		if err != nil {
+			return nil, err
+		}
+		secret, ok = secretFor(keys, kid, hasKid)
+	}
+	if !ok {
+		return nil, ErrUnknownKid
+	}
+	return secret, nil
+}
+
+// secretFor returns the secret verifying tokens with the kid. Tokens without kid are signed with JWTSECRET.
+func secretFor(keys []SigningKey, kid string, hasKid bool) ([]byte, bool) {
+	if len(keys) == 0 {
+		return config.Values.JwtSecret, !hasKid
+	}
+	if !hasKid {
+		kid = JwtSecretKid
+	}
+	for _, key := range keys {
+		if key.Kid != kid {
+			continue
+		}
+		if kid == JwtSecretKid {
+			return config.Values.JwtSecret, true
+		}
+		return key.Secret, true
+	}
+	return nil, false
+}
+
+// reloadForUnknownKid forgets the cached keys at most once per unknownKidReloadInterval,
+// so tokens with made up kids cannot make every request read the keys
+func reloadForUnknownKid() bool {
+	signingKeys.Lock()
+	defer signingKeys.Unlock()
+	if time.Since(signingKeys.reloadedForKid) < unknownKidReloadInterval {
+		return false
+	}
+	signingKeys.loaded = time.Time{}
+	signingKeys.reloadedForKid = time.Now()
+	return true
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"deployer/config"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useSigningKeys serves the keys as if stored in the database and counts how often they are read
+func useSigningKeys(t *testing.T, keys *[]SigningKey) *int {
+	t.Helper()
+	loads := 0
+	previous := LoadSigningKeys
+	LoadSigningKeys = func() ([]SigningKey, error) {
+		loads++
+		return *keys, nil
+	}
+	resetSigningKeys()
+	t.Cleanup(func() {
+		LoadSigningKeys = previous
+		resetSigningKeys()
+	})
+	return &loads
+}
+
+func resetSigningKeys() {
+	signingKeys.Lock()
+	defer signingKeys.Unlock()
+	signingKeys.keys, signingKeys.loaded, signingKeys.reloadedForKid = nil, time.Time{}, time.Time{}
+}
+
+func newTestSigningKey(t *testing.T) SigningKey {
+	t.Helper()
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signWith(t *testing.T, key SigningKey) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserId:           "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	token.Header["kid"] = key.Kid
+	signed, err := token.SignedString(key.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func verify(token string) error {
+	_, err := jwt.ParseWithClaims(token, &Claims{}, verificationKey)
+	return err
+}
+
+func TestVerificationKeyReloadsForRotatedKey(t *testing.T) {
+	first := newTestSigningKey(t)
+	keys := []SigningKey{first}
+	loads := useSigningKeys(t, &keys)
+
+	if err := verify(signWith(t, first)); err != nil {
+		t.Fatalf("token of the cached key: %v", err)
+	}
+
+	// Another replica rotates the key and signs with the new one, before the cache expires
+	rotated := newTestSigningKey(t)
+	retiredAt := time.Now()
+	first.RetiredAt = &retiredAt
+	keys = []SigningKey{rotated, first}
+
+	if err := verify(signWith(t, rotated)); err != nil {
+		t.Errorf("token of the rotated key: %v", err)
+	}
+	if *loads != 2 {
+		t.Errorf("keys read %d times, want 2", *loads)
+	}
+	if err := verify(signWith(t, first)); err != nil {
+		t.Errorf("token of the retired key: %v", err)
+	}
+}
+
+func TestVerificationKeyLimitsReloads(t *testing.T) {
+	keys := []SigningKey{newTestSigningKey(t)}
+	loads := useSigningKeys(t, &keys)
+
+	unknown := newTestSigningKey(t)
+	for range 3 {
+		err := verify(signWith(t, unknown))
+		if !errors.Is(err, ErrUnknownKid) {
+			t.Fatalf("error = %v, want ErrUnknownKid", err)
+		}
+	}
+	if *loads != 2 {
+		t.Errorf("keys read %d times for unknown kids, want 2", *loads)
+	}
+}
+
+func TestVerificationKeyWithoutKeys(t *testing.T) {
+	keys := []SigningKey{}
+	useSigningKeys(t, &keys)
+
+	previous := config.Values.JwtSecret
+	config.Values.JwtSecret = []byte("test secret")
+	t.Cleanup(func() { config.Values.JwtSecret = previous })
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserId: "user-1"})
+	signed, err := token.SignedString(config.Values.JwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(signed); err != nil {
+		t.Errorf("token signed with JWTSECRET: %v", err)
+	}
+
+	if err := verify(signWith(t, newTestSigningKey(t))); !errors.Is(err, ErrUnknownKid) {
+		t.Errorf("error = %v, want ErrUnknownKid for a kid when no keys exist", err)
+	}
+}
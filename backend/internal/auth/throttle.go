@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"deployer/config"
+	"strings"
+	"time"
+)
+
+// Reasons a login failed, recorded for auditing
+const (
+	LoginUnknownUser   = "unknown_user"
+	LoginWrongPassword = "wrong_password"
+	LoginDisabled      = "disabled"
+	LoginLocked        = "locked"
+	// A wrong TOTP or recovery code in the second step
+	LoginWrongCode = "wrong_code"
+)
+
+// LoginThrottleKeys returns the keys failed logins are counted under, for the username and for the IP.
+// Usernames are compared case-insensitively, so case variations do not get extra attempts.
+func LoginThrottleKeys(username, ip string) (string, string) {
+	username = strings.ToLower(username)
+	if len(username) > 255 {
+		username = username[:255]
+	}
+	return "user:" + username, "ip:" + ip
+}
+
+// LoginBackoff is how long further logins wait after the failures. The first free failures do not wait, then the wait
+// doubles with every failure until it reaches the lockout.
+func LoginBackoff(failures, freeAttempts int) time.Duration {
+	if failures <= freeAttempts {
+		return 0
+	}
+	lockout := LoginForgetAfter()
+	backoff := time.Duration(config.Values.LoginThrottle.BackoffSeconds) * time.Second
+	for i := freeAttempts + 1; i < failures && backoff < lockout; i++ {
+		backoff *= 2
+	}
+	return min(backoff, lockout)
+}
+
+// LoginForgetAfter is the lockout, after which failed logins are no longer counted
+func LoginForgetAfter() time.Duration {
+	return time.Duration(config.Values.LoginThrottle.LockoutMinutes) * time.Minute
+}
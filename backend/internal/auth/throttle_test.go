@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"deployer/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	previous := config.Values.LoginThrottle
+	config.Values.LoginThrottle.BackoffSeconds = 2
+	config.Values.LoginThrottle.LockoutMinutes = 1
+	t.Cleanup(func() { config.Values.LoginThrottle = previous })
+
+	tests := []struct {
+		failures     int
+		freeAttempts int
+		want         time.Duration
+	}{
+		{0, 5, 0},
+		{5, 5, 0},
+		{6, 5, 2 * time.Second},
+		{7, 5, 4 * time.Second},
+		{8, 5, 8 * time.Second},
+		{10, 5, 32 * time.Second},
+		// Capped at the lockout from here on
+		{11, 5, time.Minute},
+		{1000, 5, time.Minute},
+		{1, 0, 2 * time.Second},
+		{20, 20, 0},
+		{21, 20, 2 * time.Second},
+	}
+	for _, test := range tests {
+		if got := LoginBackoff(test.failures, test.freeAttempts); got != test.want {
+			t.Errorf("LoginBackoff(%d, %d) = %v, want %v", test.failures, test.freeAttempts, got, test.want)
+		}
+	}
+}
+
+func TestLoginBackoffLongerThanLockout(t *testing.T) {
+	previous := config.Values.LoginThrottle
+	config.Values.LoginThrottle.BackoffSeconds = 120
+	config.Values.LoginThrottle.LockoutMinutes = 1
+	t.Cleanup(func() { config.Values.LoginThrottle = previous })
+
+	if got := LoginBackoff(6, 5); got != time.Minute {
+		t.Errorf("LoginBackoff = %v, want the lockout", got)
+	}
+}
+
+func TestLoginThrottleKeys(t *testing.T) {
+	user, ip := LoginThrottleKeys("Alice", "10.0.0.1")
+	if user != "user:alice" || ip != "ip:10.0.0.1" {
+		t.Errorf("keys = %q, %q", user, ip)
+	}
+
+	long, _ := LoginThrottleKeys(strings.Repeat("a", 300), "10.0.0.1")
+	if long != "user:"+strings.Repeat("a", 255) {
+		t.Errorf("long username key has %d characters", len(long))
+	}
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"deployer/config"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TOTP as in RFC 6238 with the parameters every authenticator app supports
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// Codes of the previous and next period are accepted too, for clocks that are slightly off
+	totpSkew = 1
+)
+
+// LoginTokenPrefix starts the tokens that complete a login with a TOTP code
+const LoginTokenPrefix = "dpm_"
+
+// LoginTokenLifetime is how long the second step of a login can be completed
+const LoginTokenLifetime = 5 * time.Minute
+
+const recoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTotpSecret generates the base32 secret shared with the authenticator app
+func NewTotpSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TotpProvisioningUri is the otpauth:// URI shown as QR code to enroll the secret in an authenticator app
+func TotpProvisioningUri(username, secret string) string {
+	issuer := config.Values.Totp.Issuer
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + username,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// ValidateTotp checks the code against the secret and returns the time step it belongs to.
+// Storing the step lets callers reject a code that was already used.
+func ValidateTotp(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(key []byte, step int64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// NewRecoveryCodes generates the codes that replace a TOTP code once each, and their hashes
+func NewRecoveryCodes() ([]string, []string, error) {
+	var codes, hashes []string
+	for range recoveryCodeCount {
+		random := make([]byte, 10)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(random))
+		codes = append(codes, code[:8]+"-"+code[8:])
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code, ignoring case and dashes
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// NewLoginToken generates the token that completes a login with a TOTP code. Only the hash is stored.
+func NewLoginToken() (string, string, error) {
+	return newSecretToken(LoginTokenPrefix)
+}
+
+// TotpRequired reports whether users with the role must use TOTP
+func TotpRequired(role string) bool {
+	return slices.Contains(config.Values.Totp.RequiredRoles, role)
+}
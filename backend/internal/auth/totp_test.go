@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret of the SHA-1 test vectors in RFC 6238 appendix B, "12345678901234567890" in base32
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8 digit codes, these are their last 6 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestValidateTotpRfcVectors(t *testing.T) {
+	for _, vector := range rfcVectors {
+		step, ok := ValidateTotp(rfcSecret, vector.code, time.Unix(vector.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d rejected", vector.code, vector.unix)
+			continue
+		}
+		if want := vector.unix / totpPeriod; step != want {
+			t.Errorf("code %s at %d has step %d, want %d", vector.code, vector.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTotpSkew(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	step := at.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code := totpCode([]byte("12345678901234567890"), step+offset)
+		got, ok := ValidateTotp(rfcSecret, code, at)
+		if !ok || got != step+offset {
+			t.Errorf("code of step %+d: step %d, ok %v", offset, got, ok)
+		}
+	}
+	for _, offset := range []int64{-2, 2} {
+		code := totpCode([]byte("12345678901234567890"), step+offset)
+		if _, ok := ValidateTotp(rfcSecret, code, at); ok {
+			t.Errorf("code of step %+d accepted", offset)
+		}
+	}
+}
+
+func TestValidateTotpRejects(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfcSecret, "287083"},
+		{"too short", rfcSecret, "28708"},
+		{"too long", rfcSecret, "2870820"},
+		{"empty", rfcSecret, ""},
+		{"invalid secret", "not base32!", "287082"},
+		{"other secret", totpEncoding.EncodeToString([]byte("another secret 12345")), "287082"},
+	}
+	for _, test := range tests {
+		if _, ok := ValidateTotp(test.secret, test.code, at); ok {
+			t.Errorf("%s: code accepted", test.name)
+		}
+	}
+
+	// Secrets typed in lower case are accepted too
+	if _, ok := ValidateTotp(strings.ToLower(rfcSecret), "287082", at); !ok {
+		t.Error("lower case secret rejected")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes", len(codes), len(hashes))
+	}
+	for i, code := range codes {
+		if HashRecoveryCode(code) != hashes[i] {
+			t.Errorf("hash of %s does not match", code)
+		}
+		typed := " " + strings.ToUpper(strings.ReplaceAll(code, "-", "")) + " "
+		if HashRecoveryCode(typed) != hashes[i] {
+			t.Errorf("hash of %q, typed without dash in upper case, does not match", typed)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"deployer/internal/storage"
+	"deployer/internal/uploads"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+)
+
+// version of the archive layout, increased when a restore could not read older backups correctly
+const version = 1
+
+const (
+	manifestFile   = "manifest.json"
+	usersFile      = "users.json"
+	challengesFile = "challenges.json"
+	apiKeysFile    = "api_keys.json"
+	blobsDir       = "blobs/sha256/"
+)
+
+type manifest struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	Users      int       `json:"users"`
+	Challenges int       `json:"challenges"`
+	ApiKeys    int       `json:"api_keys"`
+}
+
+// Backup is the state of the deployer: the users, the challenges with their rows, the blobs of their artifacts, and the hashes of the API keys
+type Backup struct {
+	Users      []storage.BackupUser
+	Challenges []storage.BackupChallenge
+	ApiKeys    []storage.BackupApiKey
+	// Digests of the blobs Read put into the upload store
+	stored []string
+}
+
+// Load reads the users, challenges and API keys from the database as one consistent snapshot
+func Load() (Backup, error) {
+	var backup Backup
+	var err error
+	backup.Users, backup.Challenges, backup.ApiKeys, err = storage.ListBackup()
+	return backup, err
+}
+
+// CheckBlobs makes sure the upload store has every blob the artifacts point to, so Write does not fail after the response has started
+func (b Backup) CheckBlobs(ctx context.Context) error {
+	checked := map[string]bool{}
+	store := uploads.GetStoreSingleton()
+	for _, challenge := range b.Challenges {
+		for _, artifact := range challenge.Artifacts {
+			if checked[artifact.Sha256] {
+				continue
+			}
+			exists, err := store.Exists(ctx, uploads.BlobKey(artifact.Sha256))
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("blob %s of challenge %s is missing", artifact.Sha256, challenge.Id)
+			}
+			checked[artifact.Sha256] = true
+		}
+	}
+	return nil
+}
+
+// Write streams the backup as a tar.gz archive with the rows as JSON files, followed by every blob the artifacts point to
+func (b Backup) Write(ctx context.Context, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{manifestFile, manifest{Version: version, CreatedAt: time.Now().UTC(), Users: len(b.Users), Challenges: len(b.Challenges), ApiKeys: len(b.ApiKeys)}},
+		{usersFile, b.Users},
+		{challengesFile, b.Challenges},
+		{apiKeysFile, b.ApiKeys},
+	}
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = writeFile(archive, file.name, int64(len(content)), bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+	}
+
+	written := map[string]bool{}
+	store := uploads.GetStoreSingleton()
+	for _, challenge := range b.Challenges {
+		for _, artifact := range challenge.Artifacts {
+			if written[artifact.Sha256] {
+				continue
+			}
+			reader, size, err := store.Get(ctx, uploads.BlobKey(artifact.Sha256))
+			if err != nil {
+				return fmt.Errorf("error reading blob %s: %v", artifact.Sha256, err)
+			}
+			err = writeFile(archive, blobsDir+artifact.Sha256, size, reader)
+			reader.Close()
+			if err != nil {
+				return err
+			}
+			written[artifact.Sha256] = true
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeFile(archive *tar.Writer, name string, size int64, r io.Reader) error {
+	err := archive.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0600,
+		Size:     size,
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, r)
+	return err
+}
+
+// Read reads a backup written by Write. The manifest comes first, so a backup of a newer version is rejected before anything is stored.
+// Blobs are put into the upload store as they are read, after checking their content against the digest.
+// If the backup turns out to be invalid, the stored blobs are removed again unless artifacts point to them.
+func Read(ctx context.Context, r io.Reader) (Backup, error) {
+	backup, err := read(ctx, r)
+	if err != nil {
+		backup.removeStoredBlobs(ctx)
+	}
+	return backup, err
+}
+
+func read(ctx context.Context, r io.Reader) (Backup, error) {
+	var backup Backup
+	var manifest *manifest
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return backup, fmt.Errorf("not a valid backup: %v", err)
+	}
+	defer gz.Close()
+
+	blobs := map[string]bool{}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return backup, fmt.Errorf("not a valid backup: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if manifest == nil && header.Name != manifestFile {
+			return backup, fmt.Errorf("not a valid backup: %s does not come first", manifestFile)
+		}
+
+		switch {
+		case header.Name == manifestFile && manifest == nil:
+			err = json.NewDecoder(archive).Decode(&manifest)
+			if err == nil && manifest == nil {
+				err = fmt.Errorf("manifest is empty")
+			}
+			if err == nil && manifest.Version > version {
+				return backup, fmt.Errorf("backup has version %d, this deployer reads up to version %d", manifest.Version, version)
+			}
+		case header.Name == usersFile:
+			err = json.NewDecoder(archive).Decode(&backup.Users)
+		case header.Name == challengesFile:
+			err = json.NewDecoder(archive).Decode(&backup.Challenges)
+		case header.Name == apiKeysFile:
+			err = json.NewDecoder(archive).Decode(&backup.ApiKeys)
+		case path.Dir(header.Name)+"/" == blobsDir:
+			digest := path.Base(header.Name)
+			err = restoreBlob(ctx, digest, archive)
+			if err == nil {
+				blobs[digest] = true
+				backup.stored = append(backup.stored, digest)
+			}
+		default:
+			err = fmt.Errorf("unexpected file")
+		}
+		if err != nil {
+			return backup, fmt.Errorf("error reading %s: %v", header.Name, err)
+		}
+	}
+
+	if manifest == nil {
+		return backup, fmt.Errorf("not a valid backup: %s is missing", manifestFile)
+	}
+
+	// Blobs missing from the archive are fine if the store still has them, e.g. when only the database was lost
+	store := uploads.GetStoreSingleton()
+	for _, challenge := range backup.Challenges {
+		for _, artifact := range challenge.Artifacts {
+			if blobs[artifact.Sha256] {
+				continue
+			}
+			exists, err := store.Exists(ctx, uploads.BlobKey(artifact.Sha256))
+			if err != nil {
+				return backup, err
+			}
+			if !exists {
+				return backup, fmt.Errorf("blob %s of challenge %s is missing", artifact.Sha256, challenge.Id)
+			}
+			blobs[artifact.Sha256] = true
+		}
+	}
+	return backup, nil
+}
+
+func restoreBlob(ctx context.Context, digest string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != digest {
+		return fmt.Errorf("content does not match the digest")
+	}
+	_, _, err = uploads.PutBlob(ctx, bytes.NewReader(content))
+	return err
+}
+
+// Restore inserts the users, challenges and API keys into the database.
+// If that fails, the blobs Read stored are removed again unless other artifacts point to them.
+func (b Backup) Restore(ctx context.Context) (storage.RestoreResult, error) {
+	result, err := storage.RestoreBackup(b.Users, b.Challenges, b.ApiKeys)
+	if err != nil {
+		b.removeStoredBlobs(ctx)
+	}
+	return result, err
+}
+
+// removeStoredBlobs removes the blobs Read stored that no artifact points to
+func (b Backup) removeStoredBlobs(ctx context.Context) {
+	store := uploads.GetStoreSingleton()
+	for _, digest := range b.stored {
+		err := storage.RemoveUnreferencedBlob(digest, func() error {
+			return store.DeletePrefix(ctx, uploads.BlobKey(digest))
+		})
+		if err != nil {
+			log.Println("Failed to remove restored blob: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package ctfdclient
+
+import (
+	"log"
+
+	ctfd "github.com/ctfer-io/go-ctfd/api"
+)
+
+// New returns a client for the CTFd at url, authenticated with the API token
+func New(url, token string) (*ctfd.Client, error) {
+	nonce, session, err := ctfd.GetNonceAndSession(url)
+	if err != nil {
+		log.Println("Could not connect to CTFd: " + err.Error())
+		return nil, err
+	}
+
+	client := ctfd.NewClient(url, nonce, session, "")
+	client.SetAPIKey(token)
+	return client, nil
+}
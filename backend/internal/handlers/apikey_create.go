@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateApiKeyRequest struct {
+	Name string `json:"name"`
+	// read, write and admin
+	Scopes []string `json:"scopes"`
+	// The key does not expire if omitted
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type CreateApiKeyResponse struct {
+	storage.ApiKey
+	// Only returned once, it cannot be retrieved later
+	Key string `json:"key"`
+}
+
+// ApiKeyCreate godoc
+// @Summary      API Key Create
+// @Description  Creates an API key for the current local user, to be used like a token by scripts and CI jobs
+// @Tags         apikeys
+// @Param        key	body		CreateApiKeyRequest	true	"API key"
+// @Accept       json
+// @Produce      json
+// @Success      201 {object} handlers.CreateApiKeyResponse
+// @Router       /apikeys [post]
+// @Security BearerAuth
+func CreateApiKey(c *gin.Context) {
+	if auth.IsApiKey(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot create API keys"})
+		return
+	}
+
+	var request CreateApiKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if len(request.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scopes are required"})
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scope must be one of %s, got '%s'", strings.Join(auth.Scopes, ", "), scope)})
+			return
+		}
+	}
+	if request.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must not be negative"})
+		return
+	}
+
+	user, err := storage.GetUserById(auth.GetCurrentUserId(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not found",
+		})
+		return
+	}
+
+	key, hash, err := auth.NewApiKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var expiresAt *time.Time
+	if request.ExpiresInDays > 0 {
+		expiry := time.Now().AddDate(0, 0, request.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	apiKey, err := storage.CreateApiKey(user.Id, request.Name, key[:len(auth.ApiKeyPrefix)+6], hash, request.Scopes, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s created API key %s", user.Username, apiKey.Id)
+
+	c.JSON(http.StatusCreated, CreateApiKeyResponse{ApiKey: apiKey, Key: key})
+}
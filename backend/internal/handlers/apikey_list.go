@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApiKeyList godoc
+// @Summary      API Key List
+// @Description  Lists the API keys of the current user. Admins can list the keys of another user, or of all users with user=all.
+// @Tags         apikeys
+// @Param        user	query		string				false	"User ID"
+// @Produce      json
+// @Router       /apikeys [get]
+// @Security BearerAuth
+func ListApiKeys(c *gin.Context) {
+	userId := auth.GetCurrentUserId(c)
+	if user := c.Query("user"); user != "" && user != userId {
+		if !auth.IsAdmin(c) {
+			c.JSON(http.StatusUnauthorized, gin.H{})
+			return
+		}
+		userId = user
+		if user == "all" {
+			userId = ""
+		}
+	}
+
+	keys, err := storage.ListApiKeys(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if keys == nil {
+		keys = []storage.ApiKey{}
+	}
+	c.JSON(http.StatusOK, keys)
+}
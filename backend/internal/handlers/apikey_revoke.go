@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApiKeyRevoke godoc
+// @Summary      API Key Revoke
+// @Description  Revokes an API key of the current user, or of any user for admins
+// @Tags         apikeys
+// @Param        id	path		string				true	"API key ID"
+// @Produce      json
+// @Router       /apikeys/{id} [delete]
+// @Security BearerAuth
+func RevokeApiKey(c *gin.Context) {
+	key, err := storage.GetApiKey(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "API key not found",
+		})
+		return
+	}
+	if key.UserId != auth.GetCurrentUserId(c) && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	err = storage.RevokeApiKey(key.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("API key %s revoked", key.Id)
+
+	c.JSON(http.StatusOK, gin.H{})
+}
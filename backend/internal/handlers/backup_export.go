@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"deployer/internal/backup"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupExport godoc
+// @Summary      Backup export
+// @Description  Streams a tar.gz archive with the users and their TOTP, every challenge with its flags, revisions, artifacts and publications, the hashes of the API keys, and the uploaded files
+// @Tags         backup
+// @Produce      application/gzip
+// @Success      200 {file} file "Backup archive"
+// @Router       /backup/export [get]
+// @Security BearerAuth
+func ExportBackup(c *gin.Context) {
+	state, err := backup.Load()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = state.CheckBlobs(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("deployer-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", "application/gzip")
+	c.Status(http.StatusOK)
+
+	// The status has been sent, a failure can only be noticed by the truncated archive
+	err = state.Write(c, c.Writer)
+	if err != nil {
+		log.Println("Could not export backup: " + err.Error())
+		c.Abort()
+		return
+	}
+	log.Printf("Exported backup of %d users and %d challenges", len(state.Users), len(state.Challenges))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"deployer/internal/backup"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupRestore godoc
+// @Summary      Backup restore
+// @Description  Restores an archive from the export endpoint, usually on a fresh install. Users with an existing username are kept,
+// @Description  challenges with an existing ID are skipped, so restoring twice changes nothing. Other IDs are preserved unless they are taken.
+// @Description  The response lists the skipped challenges and maps the IDs that could not be preserved.
+// @Tags         backup
+// @Accept       mpfd
+// @Produce      json
+// @Param        backup formData file true "Backup archive"
+// @Router       /backup/restore [post]
+// @Security BearerAuth
+func RestoreBackup(c *gin.Context) {
+	file, err := c.FormFile("backup")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer src.Close()
+
+	state, err := backup.Read(c, src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := state.Restore(c)
+	if err != nil {
+		log.Println("Could not restore backup: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Restored backup with %d users and %d challenges", result.UsersCreated, result.ChallengesCreated)
+
+	c.JSON(http.StatusOK, result)
+}
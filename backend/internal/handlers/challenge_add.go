@@ -1,14 +1,10 @@
 package handlers
 
 import (
-	"deployer/config"
 	"deployer/internal/auth"
 	"deployer/internal/storage"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"slices"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,6 +20,11 @@ import (
 func AddChallenge(c *gin.Context) {
 	userId := auth.GetCurrentUserId(c)
 
+	upload, ok := bindUpload(c)
+	if !ok {
+		return
+	}
+
 	// Add challenge to DB
 	challengeId, err := storage.CreateChallenge(userId)
 	if err != nil {
@@ -31,39 +32,16 @@ func AddChallenge(c *gin.Context) {
 		return
 	}
 
-	// Store challenge file
-	form, _ := c.MultipartForm()
-	files := form.File["upload[]"]
-	dst := filepath.Join(config.Values.UploadPath, challengeId)
-	err = os.MkdirAll(dst, 0750)
+	// Store challenge files as the first revision
+	result, err := storeRevision(c, challengeId, userId, upload)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	for _, file := range files {
-		allowedFilenames := []string{"challenge.yml", "challenge.zip", "handout.zip", "solution.zip"}
-		if !slices.Contains(allowedFilenames, file.Filename) {
-			c.JSON(http.StatusBadRequest, "invalid filename")
-			return
-		}
-
-		log.Printf("Uploaded %s to %s", file.Filename, dst)
-		err = c.SaveUploadedFile(file, filepath.Join(dst, file.Filename))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		// Without a revision the challenge is unusable
+		if deleteErr := storage.DeleteChallenge(challengeId); deleteErr != nil {
+			log.Println("Failed to delete challenge of a failed upload: " + deleteErr.Error())
 		}
-	}
-
-	challengeFile := filepath.Join(dst, "challenge.yml")
-	err = storage.UpdateChallengeFlagGivenChallengeFile(challengeFile, challengeId)
-	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"challengeid": challengeId,
-	})
+	c.JSON(http.StatusOK, result)
 }
@@ -1,12 +1,10 @@
 package handlers
 
 import (
-	"deployer/config"
 	"deployer/internal/auth"
 	"deployer/internal/storage"
+	"deployer/internal/uploads"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 )
@@ -35,9 +33,14 @@ func DeleteChallenge(c *gin.Context) {
 		return
 	}
 
+	artifacts, err := storage.ListArtifacts(challenge.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Delete challenge files
-	dst := filepath.Join(config.Values.UploadPath, challengeId)
-	err = os.RemoveAll(dst)
+	err = uploads.GetStoreSingleton().DeletePrefix(c, uploads.ChallengeDir(challengeId))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -49,6 +52,13 @@ func DeleteChallenge(c *gin.Context) {
 		return
 	}
 
+	// Blobs may be shared with other challenges with identical files
+	err = removeUnreferencedBlobs(c, artifacts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"challengeid": challengeId,
 	})
@@ -1,11 +1,8 @@
 package handlers
 
 import (
-	"deployer/config"
 	"deployer/internal/storage"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +12,21 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// instanceRevision is the revision the instance was started with, so its files match the digest it verifies them against
+func instanceRevision(instance storage.Instance, challenge storage.Challenge) int {
+	if instance.Revision == 0 {
+		return challenge.Revision
+	}
+	return instance.Revision
+}
+
 // @Summary Download challenge
-// @Description Downloads a challenge
+// @Description Downloads the challenge.zip of the revision the instance was started with
 // @Tags challenges
 // @Param id path string true "Challenge ID"
 // @Param token query string true "Token"
 // @Success 200 {file} file "Challenge file"
+// @Header 200 {string} X-Checksum-Sha256 "SHA-256 digest of the file"
 // @Failure 401 {object} handlers.ErrorResponse "Unauthorized"
 // @Failure 404 {object} handlers.ErrorResponse "File not found"
 // @Router /challenges/{id}/download [get]
@@ -34,12 +40,11 @@ func DownloadChallenge(c *gin.Context) {
 		return
 	}
 
-	file := filepath.Join(config.Values.UploadPath, challengeId, "challenge.zip")
-	_, err = os.Stat(file)
+	challenge, err := storage.GetChallenge(challengeId)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
 		return
 	}
 
-	c.FileAttachment(file, "challenge.zip")
+	sendArtifact(c, challenge.Id, instanceRevision(instance, challenge), "challenge.zip")
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"deployer/internal/auth"
+	"deployer/internal/publisher"
+	"deployer/internal/storage"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChallengeHide godoc
+// @Summary      Challenge Hide
+// @Description  Hides a published challenge from players
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Param        target	query		string				false	"Publish target, the default target if omitted"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/hide [post]
+// @Security BearerAuth
+func HideChallenge(c *gin.Context) {
+	setChallengeState(c, "hidden")
+}
+
+// ChallengeUnhide godoc
+// @Summary      Challenge Unhide
+// @Description  Makes a hidden challenge visible to players
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Param        target	query		string				false	"Publish target, the default target if omitted"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/unhide [post]
+// @Security BearerAuth
+func UnhideChallenge(c *gin.Context) {
+	setChallengeState(c, "visible")
+}
+
+func setChallengeState(c *gin.Context, state string) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Challenge not found",
+		})
+		return
+	}
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	publication, p, ok := getPublication(c, challenge.Id)
+	if !ok {
+		return
+	}
+
+	err = p.SetState(c, publication.CtfdId, state)
+	if err != nil {
+		log.Println("Could not change challenge state: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = storage.SetPublicationState(challenge.Id, publication.Target, state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if publication.Target == publisher.DefaultTarget() {
+		err = storage.SetChallengeState(challenge.Id, state)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	log.Printf("Challenge %s is now %s on %s", challenge.Id, state, publication.Target)
+
+	c.JSON(http.StatusOK, gin.H{
+		"challengeid": challenge.Id,
+		"target":      publication.Target,
+		"state":       state,
+	})
+}
+
+// getPublication returns the publication of the challenge on the target given in the query, and the publisher of the target.
+// It writes the error response itself and returns false on failure.
+func getPublication(c *gin.Context, challengeId string) (storage.Publication, publisher.Publisher, bool) {
+	target := c.DefaultQuery("target", publisher.DefaultTarget())
+	p, err := publisher.GetPublisher(target)
+	if errors.Is(err, publisher.ErrUnknownTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown publish target '%s'", target)})
+		return storage.Publication{}, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return storage.Publication{}, nil, false
+	}
+
+	publication, err := storage.GetPublication(challengeId, target)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Challenge is not published to " + target,
+		})
+		return publication, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return publication, nil, false
+	}
+	return publication, p, true
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"deployer/config"
+	"deployer/internal/auth"
+	"deployer/internal/importer"
+	"deployer/internal/storage"
+	"deployer/internal/validation"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type importResult struct {
+	// Directory of the challenge in the repository
+	Dir         string `json:"dir"`
+	Name        string `json:"name"`
+	ChallengeId string `json:"challengeid,omitempty"`
+	// created, updated, unchanged or failed
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+	// Validation problems of the packed files
+	Files []*validation.FileReport `json:"files,omitempty"`
+	// The stored revision if the challenge was created or updated
+	Upload *uploadResult `json:"upload,omitempty"`
+}
+
+// ChallengeImport godoc
+// @Summary      Challenge Import
+// @Description  Imports a tar or tar.gz archive of a ctfcli-style repository. Every directory with a challenge.yml is a challenge,
+// @Description  its src, solution and handout directories are packed into challenge.zip, solution.zip and handout.zip.
+// @Description  Challenges are matched by name: new ones are created, changed ones get a new revision.
+// @Tags         challenges
+// @Accept       mpfd
+// @Produce      json
+// @Param        repository formData file true "Repository archive"
+// @Router       /challenges/import [post]
+// @Security BearerAuth
+func ImportChallenges(c *gin.Context) {
+	userId := auth.GetCurrentUserId(c)
+
+	file, err := c.FormFile("repository")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if file.Size > config.Values.UploadLimits.MaxImportBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repository has %d bytes, the limit is %d", file.Size, config.Values.UploadLimits.MaxImportBytes)})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer src.Close()
+
+	sources, err := importer.ReadRepository(src, config.Values.UploadLimits.MaxImportBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := []importResult{}
+	names := map[string]string{}
+	for _, source := range sources {
+		result := importChallenge(c, userId, source, names)
+		if result.Action == "failed" {
+			log.Printf("Could not import %s: %s", source.Dir, result.Error)
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenges": results})
+}
+
+// importChallenge creates or updates the challenge with the name in challenge.yml.
+// names maps the names imported so far to their directory, so a repository cannot import the same challenge twice.
+func importChallenge(c *gin.Context, userId string, source importer.Source, names map[string]string) importResult {
+	result := importResult{Dir: source.Dir, Action: "failed"}
+
+	report := validation.NewReport()
+	for _, filename := range allowedFilenames {
+		if content := source.Upload[filename]; int64(len(content)) > config.Values.UploadLimits.MaxFileBytes {
+			report.Errorf(filename, "file has %d bytes, the limit is %d", len(content), config.Values.UploadLimits.MaxFileBytes)
+		}
+	}
+	validation.ValidateUpload(source.Upload, report)
+	if report.HasErrors() {
+		result.Error = "Upload validation failed"
+		result.Files = report.Files
+		return result
+	}
+
+	conf, err := storage.ParseChallengeYAML(source.Upload["challenge.yml"])
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Name = conf.Name
+	if dir, ok := names[conf.Name]; ok {
+		result.Error = fmt.Sprintf("name is also used by %s", dir)
+		return result
+	}
+	names[conf.Name] = source.Dir
+
+	challenges, _, err := storage.ListChallenges(storage.ChallengeFilter{Name: conf.Name})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if len(challenges) > 1 {
+		result.Error = fmt.Sprintf("name matches %d challenges", len(challenges))
+		return result
+	}
+
+	if len(challenges) == 0 {
+		challengeId, err := storage.CreateChallenge(userId)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.ChallengeId = challengeId
+
+		upload, err := storeRevision(c, challengeId, userId, source.Upload)
+		if err != nil {
+			// Without a revision the challenge is unusable, and importing again would not find it by name
+			if deleteErr := storage.DeleteChallenge(challengeId); deleteErr != nil {
+				log.Println("Failed to delete challenge of a failed import: " + deleteErr.Error())
+			} else {
+				result.ChallengeId = ""
+			}
+			result.Error = err.Error()
+			return result
+		}
+		result.Action = "created"
+		result.Upload = &upload
+		return result
+	}
+
+	challenge := challenges[0]
+	result.ChallengeId = challenge.Id
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		result.Error = "Challenge belongs to another user"
+		return result
+	}
+
+	unchanged, err := sameAsRevision(challenge.Id, challenge.Revision, source.Upload)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if unchanged {
+		result.Action = "unchanged"
+		return result
+	}
+
+	// Store the files as a new revision, which has to be verified again
+	upload, err := storeRevision(c, challenge.Id, userId, source.Upload)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	err = storage.ResetChallengeVerified(challenge.Id)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Action = "updated"
+	result.Upload = &upload
+	return result
+}
+
+// sameAsRevision reports whether the revision has exactly the files of the upload
+func sameAsRevision(challengeId string, revision int, upload validation.Upload) (bool, error) {
+	for _, filename := range allowedFilenames {
+		content, uploaded := upload[filename]
+		artifact, err := storage.GetArtifact(challengeId, revision, filename)
+		if errors.Is(err, sql.ErrNoRows) {
+			if uploaded {
+				return false, nil
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		digest := sha256.Sum256(content)
+		if !uploaded || artifact.Sha256 != hex.EncodeToString(digest[:]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
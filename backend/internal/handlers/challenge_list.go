@@ -3,22 +3,65 @@ package handlers
 import (
 	"deployer/internal/auth"
 	"deployer/internal/storage"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ChallengeList godoc
 // @Summary      Callenges List
+// @Description  Lists challenges with the metadata of their challenge.yml. Developers only see their own challenges.
 // @Tags         challenges
+// @Param        category	query		string	false	"Only challenges in this category"
+// @Param        tag	query		string	false	"Only challenges with this tag"
+// @Param        published	query		bool	false	"Only published or unpublished challenges"
+// @Param        verified	query		bool	false	"Only verified or unverified challenges"
+// @Param        search	query		string	false	"Text to find in name, category, author or tags"
+// @Param        sort	query		string	false	"name, category, author, value, state or created, prefixed with - for descending order"
+// @Param        limit	query		int	false	"Maximum number of challenges to return"
+// @Param        offset	query		int	false	"Number of challenges to skip"
 // @Accept       json
 // @Produce      json
 // @Router       /challenges [get]
 // @Security BearerAuth
 func ListChallenges(c *gin.Context) {
-	isAdmin := auth.IsAdmin(c)
-	userid := auth.GetCurrentUserId(c)
-	res, err := storage.ListChallenges(isAdmin, userid)
+	filter := storage.ChallengeFilter{
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		Search:   c.Query("search"),
+	}
+	if !auth.IsAdmin(c) {
+		filter.UserId = auth.GetCurrentUserId(c)
+	}
+
+	var err error
+	filter.Published, err = queryBool(c, "published")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Verified, err = queryBool(c, "verified")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter.Sort, filter.Descending = strings.CutPrefix(c.Query("sort"), "-")
+	filter.Limit, err = queryInt(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Offset, err = queryInt(c, "offset")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, total, err := storage.ListChallenges(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -26,5 +69,30 @@ func ListChallenges(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"challenges": res,
+		"total":      total,
 	})
 }
+
+func queryBool(c *gin.Context, key string) (*bool, error) {
+	value, ok := c.GetQuery(key)
+	if !ok {
+		return nil, nil
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &result, nil
+}
+
+func queryInt(c *gin.Context, key string) (int, error) {
+	value, ok := c.GetQuery(key)
+	if !ok {
+		return 0, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil || result < 0 {
+		return 0, fmt.Errorf("invalid %s", key)
+	}
+	return result, nil
+}
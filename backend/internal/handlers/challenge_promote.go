@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"database/sql"
+	"deployer/internal/publisher"
+	"deployer/internal/storage"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChallengePromote godoc
+// @Summary      Challenge Promote
+// @Description  Publishes the revision published to one target, e.g. staging, to another, e.g. production. The revision must be the verified active revision.
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Param        from	query		string				true	"Target the revision is published to"
+// @Param        to	query		string				false	"Target to publish to, the default target if omitted"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/promote [post]
+// @Security BearerAuth
+func PromoteChallenge(c *gin.Context) {
+	challengeId := c.Param("id")
+	from := c.Query("from")
+	to := c.DefaultQuery("to", publisher.DefaultTarget())
+
+	if from == "" || from == to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must name another target than to"})
+		return
+	}
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Challenge not found",
+		})
+		return
+	}
+
+	source, err := storage.GetPublication(challenge.Id, from)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Challenge is not published to " + from,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Verification applies to the active revision, so only that revision can be promoted
+	if !challenge.Verified || source.Revision != challenge.Revision {
+		c.JSON(http.StatusConflict, gin.H{
+			"message":           "Only a verified revision can be promoted. Verify the active revision and publish it to " + from + " first.",
+			"revision":          source.Revision,
+			"active_revision":   challenge.Revision,
+			"revision_verified": challenge.Verified,
+		})
+		return
+	}
+
+	publication, ok := publishRevision(c, &challenge, source.Revision, to)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusCreated, publication)
+}
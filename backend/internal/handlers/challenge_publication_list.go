@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/publisher"
+	"deployer/internal/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChallengePublicationList godoc
+// @Summary      Challenge Publication List
+// @Description  Lists the targets the challenge is published to, with its ID and revision on each
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/publications [get]
+// @Security BearerAuth
+func ListChallengePublications(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Challenge not found",
+		})
+		return
+	}
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	publications, err := storage.ListPublications(challenge.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"publications":   publications,
+		"targets":        publisher.Targets(),
+		"default_target": publisher.DefaultTarget(),
+	})
+}
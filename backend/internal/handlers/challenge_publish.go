@@ -1,70 +1,29 @@
 package handlers
 
 import (
-	"deployer/config"
+	"context"
+	"database/sql"
 	"deployer/internal/auth"
+	"deployer/internal/publisher"
 	"deployer/internal/storage"
+	"deployer/internal/uploads"
+	"deployer/internal/validation"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 
-	ctfd "github.com/ctfer-io/go-ctfd/api"
 	"github.com/gin-gonic/gin"
-	"gopkg.in/yaml.v2"
 )
 
-type ChallengeCtfd struct {
-	Name           string        `json:"name"`
-	Author         string        `json:"author"`
-	Category       string        `json:"category"`
-	Description    string        `json:"description"`
-	Value          int           `json:"value"`
-	Type           string        `json:"type"`
-	Extra          *Extra        `json:"extra"`
-	Image          interface{}   `json:"image"`
-	Protocol       interface{}   `json:"protocol"`
-	Host           interface{}   `json:"host"`
-	ConnectionInfo string        `json:"connection_info"`
-	Healthcheck    string        `json:"healthcheck"`
-	Attempts       int           `json:"attempts"`
-	Flags          []string      `json:"flags"`
-	Topics         []string      `json:"topics"`
-	Tags           []string      `json:"tags"`
-	Files          []string      `json:"files"`
-	Hints          []HintElement `json:"hints"`
-	Requirements   []string      `json:"requirements"`
-	State          string        `json:"state"`
-	Version        string        `json:"version"`
-}
-
-type Extra struct {
-	Initial  int    `json:"initial"`
-	Decay    int    `json:"decay"`
-	Minimum  int    `json:"minimum"`
-	Function string `json:"function"`
-}
-
-type FlagClass struct {
-	Type    string  `json:"type"`
-	Content string  `json:"content"`
-	Data    *string `json:"data,omitempty"`
-}
-
-type HintClass struct {
-	Content string `json:"content"`
-	Cost    int    `json:"cost"`
-}
-
-type HintElement struct {
-	HintClass *HintClass
-	String    *string
-}
-
 // ChallengePublish godoc
 // @Summary      Challenge Publish
+// @Description  Publishes the active revision, or updates the challenge if it was published to the target before
 // @Tags         challenges
 // @Param        id	path		string				true	"Challenge ID"
+// @Param        target	query		string				false	"Publish target, the default target if omitted"
 // @Accept       json
 // @Produce      json
 // @Router       /challenges/{id}/publish [post]
@@ -72,6 +31,7 @@ type HintElement struct {
 func PublishChallenge(c *gin.Context) {
 	challengeId := c.Param("id")
 	userId := auth.GetCurrentUserId(c)
+	target := c.DefaultQuery("target", publisher.DefaultTarget())
 	challenge, err := storage.GetChallenge(challengeId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -82,113 +42,228 @@ func PublishChallenge(c *gin.Context) {
 		return
 	}
 
-	// Publish to CTFd
-	nonce, session, err := ctfd.GetNonceAndSession(config.Values.CTFDURL)
-	if err != nil {
-		log.Println("Could not connect to CTFd: " + err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	publication, ok := publishRevision(c, &challenge, challenge.Revision, target)
+	if !ok {
 		return
 	}
 
-	client := ctfd.NewClient(config.Values.CTFDURL, nonce, session, "")
-	client.SetAPIKey(config.Values.CTFDAPIToken)
+	c.IndentedJSON(http.StatusCreated, publication)
+}
 
-	dst := filepath.Join(config.Values.UploadPath, challenge.Id, "challenge.yml")
-	yamlFile, err := os.ReadFile(dst)
+// publishRevision publishes a revision of the challenge to the target and records the publication.
+// It writes the error response itself and returns false on failure.
+func publishRevision(c *gin.Context, challenge *storage.Challenge, number int, target string) (storage.Publication, bool) {
+	var publication storage.Publication
+	p, err := publisher.GetPublisher(target)
+	if errors.Is(err, publisher.ErrUnknownTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown publish target '%s'", target)})
+		return publication, false
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return publication, false
 	}
 
-	conf := &ChallengeCtfd{}
-	err = yaml.Unmarshal(yamlFile, conf)
+	revision, err := checkRevision(c, challenge.Id, number)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return publication, false
+	}
+	if len(revision.Leaks) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Flags were found in the handout or challenge.yml, or parts of them could not be scanned. Upload a fixed revision before publishing.",
+			"leaks": revision.Leaks,
+		})
+		return publication, false
+	}
+	if len(revision.PolicyViolations) > 0 && revision.PolicyOverrideBy == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":             "compose.yaml violates the security policy. Upload a fixed revision or ask an admin to override the policy.",
+			"policy_violations": revision.PolicyViolations,
+		})
+		return publication, false
 	}
 
-	var ch *ctfd.Challenge
+	conf, err := readChallengeConfig(c, challenge.Id, number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return publication, false
+	}
 
-	if challenge.CtfdId.Valid {
-		err = client.DeleteChallenge(int(challenge.CtfdId.Int64))
-		if err != nil {
-			log.Println("Could not delete from CTFd: " + err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	flags, err := conf.FlagClasses()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return publication, false
 	}
 
-	// Add challenge
-	log.Printf("Adding challenge of type: '%s'\n", conf.Type)
-
-	// Allow omitting extra values
-	function := "linear"
-	inital := conf.Value
-	decay := 0
-	minimum := conf.Value
-	if conf.Extra != nil {
-		function = conf.Extra.Function
-		inital = conf.Extra.Initial
-		decay = conf.Extra.Decay
-		minimum = conf.Extra.Minimum
-	}
-	ch, err = client.PostChallenges(&ctfd.PostChallengesParams{
-		Name:           conf.Name,
-		Category:       conf.Category,
-		Description:    conf.Description,
-		ConnectionInfo: &conf.ConnectionInfo,
-		Value:          conf.Value,
-		MaxAttempts:    &conf.Attempts,
-		Function:       function,
-		Initial:        &inital,
-		Decay:          &decay,
-		Minimum:        &minimum,
-		State:          conf.State,
-		Type:           conf.Type,
-	})
+	requirements, err := resolveRequirements(conf.Requirements, target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return publication, false
+	}
 
+	files, err := readChallengeFiles(c, challenge.Id, number, conf)
 	if err != nil {
-		log.Println("Could not add challenge to CTFd: " + err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return publication, false
 	}
 
-	// Upload handout
-	file := filepath.Join(config.Values.UploadPath, challenge.Id, "handout.zip")
-	data, err := os.ReadFile(file)
+	var ctfdId int
+	// Players keep the revision published before until publishing this one succeeds
+	liveRevision := number
+	state := conf.InitialState()
+	previous, err := storage.GetPublication(challenge.Id, target)
 	if err == nil {
-		_, err = client.PostFiles(&ctfd.PostFilesParams{
-			Files: []*ctfd.InputFile{
-				{
-					Name:    "handout.zip",
-					Content: data,
-				},
-			},
-			Challenge: &ch.ID,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+		ctfdId = previous.CtfdId
+		liveRevision = previous.Revision
+		state = previous.State
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return publication, false
 	}
+	// Publishing again keeps the visibility set by hiding or unhiding instead of the state in challenge.yml
+	conf.State = state
 
-	_, err = client.PostFlags(&ctfd.PostFlagsParams{
-		Challenge: ch.ID,
-		Content:   conf.Flags[0],
-		Type:      "static",
+	ctfdId, err = publisher.Publish(c, p, ctfdId, publisher.Challenge{
+		Config:                conf,
+		Flags:                 flags,
+		Requirements:          requirements,
+		AnonymizeRequirements: conf.Requirements.Anonymize,
+		Files:                 files,
 	})
+	if err != nil && ctfdId == 0 {
+		log.Println("Could not publish challenge: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return publication, false
+	}
+
+	// Record the publication even if publishing failed halfway, so publishing again updates the challenge instead of adding a duplicate
+	if err == nil {
+		liveRevision = number
+	}
+	if referenceErr := recordPublication(challenge.Id, target, ctfdId, liveRevision, state); err == nil {
+		err = referenceErr
+	}
 	if err != nil {
+		log.Println("Could not publish challenge: " + err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return publication, false
 	}
+	log.Printf("Challenge %s revision %d published to %s", challenge.Id, number, target)
 
-	// Change challenge status
-	err = storage.PublishChallengeWithReference(challenge.Id, ch.ID)
+	publication, err = storage.GetPublication(challenge.Id, target)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return publication, false
+	}
+	return publication, true
+}
+
+// recordPublication stores the ID on the target. The default target is also kept in the published, ctfd_id and state columns of the challenge.
+func recordPublication(challengeId, target string, ctfdId, revision int, state string) error {
+	err := storage.SavePublication(challengeId, target, ctfdId, revision, state)
+	if err != nil || target != publisher.DefaultTarget() {
+		return err
+	}
+	return storage.PublishChallengeWithReference(challengeId, ctfdId, state)
+}
+
+// checkRevision returns the revision, running the leak scan and the compose policy check first if it was uploaded before they existed
+func checkRevision(ctx context.Context, challengeId string, number int) (storage.Revision, error) {
+	revision, err := storage.GetRevision(challengeId, number)
+	if err != nil {
+		return revision, err
+	}
+	if revision.LeakScanPassed != nil && revision.PolicyCheckPassed != nil {
+		return revision, nil
+	}
+
+	upload, err := readUpload(ctx, challengeId, number, "challenge.yml", "challenge.zip", "handout.zip")
+	if err != nil {
+		return revision, err
+	}
+	if revision.LeakScanPassed == nil {
+		revision.Leaks = validation.ScanFlagLeaks(upload)
+		err = storage.SetRevisionLeaks(challengeId, number, revision.Leaks)
+		if err != nil {
+			return revision, err
+		}
+	}
+	if revision.PolicyCheckPassed == nil {
+		revision.PolicyViolations = validation.CheckComposePolicy(upload)
+		err = storage.SetRevisionPolicyViolations(challengeId, number, revision.PolicyViolations)
+		if err != nil {
+			return revision, err
+		}
+	}
+	return revision, nil
+}
+
+// resolveRequirements maps the prerequisites in challenge.yml to IDs on the target.
+// A prerequisite is the name of a challenge published to the target, or an ID if no such challenge has that name.
+func resolveRequirements(requirements storage.Requirements, target string) ([]int, error) {
+	var result []int
+	for _, prerequisite := range requirements.Prerequisites {
+		challenges, _, err := storage.ListChallenges(storage.ChallengeFilter{Name: prerequisite})
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []int
+		for _, challenge := range challenges {
+			publication, err := storage.GetPublication(challenge.Id, target)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, publication.CtfdId)
+		}
+		if len(ids) > 1 {
+			return nil, fmt.Errorf("prerequisite '%s' matches %d published challenges", prerequisite, len(ids))
+		}
+		if len(ids) == 1 {
+			result = append(result, ids[0])
+			continue
+		}
+		if id, err := strconv.Atoi(prerequisite); err == nil {
+			result = append(result, id)
+			continue
+		}
+		return nil, fmt.Errorf("prerequisite '%s' is not published to %s", prerequisite, target)
+	}
+	return result, nil
+}
+
+// readChallengeFiles returns the files of the revision to attach to the published challenge.
+// Files listed in challenge.yml are taken from handout.zip. Without a list, the whole handout.zip is attached.
+func readChallengeFiles(ctx context.Context, challengeId string, revision int, conf storage.ChallengeCtfd) ([]publisher.File, error) {
+	handout, err := readArtifact(ctx, challengeId, revision, "handout.zip")
+	if errors.Is(err, uploads.ErrNotFound) {
+		if len(conf.Files) > 0 {
+			return nil, fmt.Errorf("challenge.yml lists files but handout.zip was not uploaded")
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conf.Files) == 0 {
+		return []publisher.File{{Name: "handout.zip", Content: handout}}, nil
 	}
-	log.Println("Challenge status changed to published")
 
-	c.IndentedJSON(http.StatusCreated, gin.H{})
+	var result []publisher.File
+	for _, file := range conf.Files {
+		content := handout
+		if file != "handout.zip" {
+			content, err = validation.ReadArchiveFile(handout, file)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s from handout.zip: %v", file, err)
+			}
+		}
+		result = append(result, publisher.File{Name: path.Base(file), Content: content})
+	}
+	return result, nil
 }
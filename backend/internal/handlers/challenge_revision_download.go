@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Download challenge revision
+// @Description Downloads a file of a challenge revision
+// @Tags challenges
+// @Security BearerAuth
+// @Param id path string true "Challenge ID"
+// @Param revision path int true "Revision number"
+// @Param file query string true "File name: challenge.yml, challenge.zip, handout.zip or solution.zip"
+// @Success 200 {file} file "Revision file"
+// @Header 200 {string} X-Checksum-Sha256 "SHA-256 digest of the file"
+// @Failure 401 {object} handlers.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handlers.ErrorResponse "File not found"
+// @Router /challenges/{id}/revisions/{revision}/download [get]
+func DownloadChallengeRevision(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+	filename := c.Query("file")
+
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+	if !slices.Contains(allowedFilenames, filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
+		return
+	}
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	_, err = storage.GetRevision(challenge.Id, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	sendArtifact(c, challenge.Id, number, filename)
+}
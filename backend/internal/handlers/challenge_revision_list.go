@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChallengeRevisionList godoc
+// @Summary      Challenge Revision List
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/revisions [get]
+// @Security BearerAuth
+func ListChallengeRevisions(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Challenge not found",
+		})
+		return
+	}
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	res, err := storage.ListRevisions(challenge.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"active":    challenge.Revision,
+		"revisions": res,
+	})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PolicyOverride struct {
+	// False withdraws a previous override
+	Override bool `json:"override"`
+}
+
+// ChallengeRevisionOverride godoc
+// @Summary      Challenge Revision Policy Override
+// @Description  Allows a revision to be published even though its compose.yaml violates the security policy
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Param        revision	path		int				true	"Revision number"
+// @Param        request	body		PolicyOverride	true	"Override"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/revisions/{revision}/override [post]
+// @Security BearerAuth
+func OverrideChallengeRevisionPolicy(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+
+	var body PolicyOverride
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision, err := storage.GetRevision(challengeId, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Revision not found",
+		})
+		return
+	}
+
+	var overrideBy *string
+	if body.Override {
+		overrideBy = &userId
+	}
+	err = storage.SetRevisionPolicyOverride(revision.ChallengeId, revision.Number, overrideBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challengeid":        revision.ChallengeId,
+		"revision":           revision.Number,
+		"policy_violations":  revision.PolicyViolations,
+		"policy_override_by": overrideBy,
+	})
+}
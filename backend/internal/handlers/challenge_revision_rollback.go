@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChallengeRevisionRollback godoc
+// @Summary      Challenge Revision Rollback
+// @Description  Makes a previous revision the active revision of the challenge
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Param        revision	path		int				true	"Revision number"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/revisions/{revision}/rollback [post]
+// @Security BearerAuth
+func RollbackChallengeRevision(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Challenge not found",
+		})
+		return
+	}
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	_, err = storage.GetRevision(challenge.Id, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Revision not found",
+		})
+		return
+	}
+
+	err = activateRevision(c, challenge.Id, number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = storage.ResetChallengeVerified(challenge.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challengeid": challenge.Id,
+		"revision":    number,
+	})
+}
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"deployer/config"
 	"deployer/internal/auth"
 	"deployer/internal/infrastructure"
+	"deployer/internal/publisher"
 	"deployer/internal/storage"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -70,15 +74,20 @@ func StartChallenge(c *gin.Context) {
 		return
 	}
 
-	instanceId, token, err := storage.CreateInstance(userId, challenge.Id)
+	revision, err := playerRevision(c, challenge)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	instance, err := storage.CreateInstance(userId, challenge.Id, revision)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	testMode := false
-	challengeDomain := getChallengeDomain(instanceId)
-	res, err := createResources(c, userId, &challenge, instanceId, token, challengeDomain, testMode)
+	challengeDomain := getChallengeDomain(instance.Id)
+	res, err := createResources(c, userId, &challenge, &instance, challengeDomain, testMode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -86,6 +95,20 @@ func StartChallenge(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// playerRevision returns the revision an instance of the challenge runs. Players get the revision published to the default target,
+// which only changes when publishing succeeds, so uploads to a published challenge do not reach them before it is published again.
+// Its developer and admins get the active revision, to test it before publishing.
+func playerRevision(c *gin.Context, challenge storage.Challenge) (int, error) {
+	if !challenge.Published || challenge.UserId == auth.GetCurrentUserId(c) || auth.IsAdmin(c) {
+		return challenge.Revision, nil
+	}
+	publication, err := storage.GetPublication(challenge.Id, publisher.DefaultTarget())
+	if errors.Is(err, sql.ErrNoRows) {
+		return challenge.Revision, nil
+	}
+	return publication.Revision, err
+}
+
 func getChallengeDomain(instanceId string) string {
 	if instanceId == "" {
 		return config.Values.ChallengeDomain
@@ -93,19 +116,32 @@ func getChallengeDomain(instanceId string) string {
 	return instanceId[0:18] + config.Values.ChallengeDomain
 }
 
-func createResources(ctx context.Context, userId string, challenge *storage.Challenge, instanceId, token string, challengeDomain string, testMode bool) (*StartChallengeResponse, error) {
+func createResources(ctx context.Context, userId string, challenge *storage.Challenge, instance *storage.Instance, challengeDomain string, testMode bool) (*StartChallengeResponse, error) {
 	kubeClient, err := infrastructure.CreateClient()
 	if err != nil {
 		return nil, err
 	}
 
-	ns := infrastructure.BuildNamespace(challenge.Id, instanceId, userId, testMode)
+	// The instance verifies the downloaded archive against the digest recorded on upload
+	archive := "challenge.zip"
+	if testMode {
+		archive = "solution.zip"
+	}
+	artifact, err := storage.GetArtifact(challenge.Id, instance.Revision, archive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s was not uploaded", archive)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ns := infrastructure.BuildNamespace(challenge.Id, instance.Id, userId, testMode)
 
 	var mainResource client.Object
 	if useVm := unleash.IsEnabled("use-virtual-machine"); useVm {
-		mainResource = infrastructure.BuildVm(challenge.Id, userId, token, ns.Name, challengeDomain, testMode)
+		mainResource = infrastructure.BuildVm(challenge.Id, userId, instance.Token, instance.Flag, artifact.Sha256, ns.Name, challengeDomain, testMode)
 	} else {
-		mainResource = infrastructure.BuildContainer(challenge.Id, userId, token, ns.Name, challengeDomain, testMode)
+		mainResource = infrastructure.BuildContainer(challenge.Id, userId, instance.Token, instance.Flag, artifact.Sha256, ns.Name, challengeDomain, testMode)
 	}
 
 	resources := []client.Object{
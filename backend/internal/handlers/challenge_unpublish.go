@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/infrastructure"
+	"deployer/internal/publisher"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChallengeUnpublish godoc
+// @Summary      Challenge Unpublish
+// @Description  Removes the challenge from the platform of the target. Unpublishing from the default target also stops the running player instances. The challenge can be updated and published again afterwards.
+// @Tags         challenges
+// @Param        id	path		string				true	"Challenge ID"
+// @Param        target	query		string				false	"Publish target, the default target if omitted"
+// @Accept       json
+// @Produce      json
+// @Router       /challenges/{id}/unpublish [post]
+// @Security BearerAuth
+func UnpublishChallenge(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	challenge, err := storage.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Challenge not found",
+		})
+		return
+	}
+	if challenge.UserId != userId && !auth.IsAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		return
+	}
+
+	publication, p, ok := getPublication(c, challenge.Id)
+	if !ok {
+		return
+	}
+
+	err = p.Delete(c, publication.CtfdId)
+	if err != nil {
+		log.Println("Could not delete published challenge: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = storage.DeletePublication(challenge.Id, publication.Target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Challenge %s unpublished from %s", challenge.Id, publication.Target)
+
+	// Players start instances through the default target
+	stopped := 0
+	if publication.Target == publisher.DefaultTarget() {
+		err = storage.UnpublishChallenge(challenge.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Println("Challenge status changed to unpublished")
+
+		stopped, err = infrastructure.StopPlayerInstances(c, challenge.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challengeid":       challenge.Id,
+		"target":            publication.Target,
+		"stopped_instances": stopped,
+	})
+}
@@ -1,14 +1,9 @@
 package handlers
 
 import (
-	"deployer/config"
 	"deployer/internal/auth"
 	"deployer/internal/storage"
-	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"slices"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,46 +33,13 @@ func UpdateChallenge(c *gin.Context) {
 		return
 	}
 
-	if challenge.Published {
-		c.JSON(http.StatusForbidden, gin.H{
-			"message": "Challenge is published. You cannot update a playable challenge.",
-		})
-		return
-	}
-
-	// Replace challenge files
-	dst := filepath.Join(config.Values.UploadPath, challengeId)
-	err = os.RemoveAll(dst)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	err = os.MkdirAll(dst, 0750)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	upload, ok := bindUpload(c)
+	if !ok {
 		return
 	}
 
-	form, _ := c.MultipartForm()
-	files := form.File["upload[]"]
-
-	for _, file := range files {
-		allowedFilenames := []string{"challenge.yml", "challenge.zip", "handout.zip", "solution.zip"}
-		if !slices.Contains(allowedFilenames, file.Filename) {
-			c.JSON(http.StatusBadRequest, "invalid filename")
-			return
-		}
-
-		log.Printf("Uploaded %s to %s", file.Filename, dst)
-		err = c.SaveUploadedFile(file, filepath.Join(dst, file.Filename))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	challengeFile := filepath.Join(dst, "challenge.yml")
-	err = storage.UpdateChallengeFlagGivenChallengeFile(challengeFile, challengeId)
+	// Store challenge files as a new revision, previous revisions are kept
+	result, err := storeRevision(c, challengeId, userId, upload)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -89,7 +51,5 @@ func UpdateChallenge(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"challengeid": challengeId,
-	})
+	c.JSON(http.StatusOK, result)
 }
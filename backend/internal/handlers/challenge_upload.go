@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"deployer/config"
+	"deployer/internal/storage"
+	"deployer/internal/uploads"
+	"deployer/internal/validation"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+var allowedFilenames = []string{"challenge.yml", "challenge.zip", "handout.zip", "solution.zip"}
+
+const checksumHeader = "X-Checksum-Sha256"
+
+// bindUpload reads and validates the uploaded files. If it fails, the error response has been sent.
+func bindUpload(c *gin.Context) (validation.Upload, bool) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	upload := validation.Upload{}
+	report := validation.NewReport()
+	for _, file := range form.File["upload[]"] {
+		if !slices.Contains(allowedFilenames, file.Filename) {
+			c.JSON(http.StatusBadRequest, "invalid filename")
+			return nil, false
+		}
+		if _, ok := upload[file.Filename]; ok {
+			report.Errorf(file.Filename, "file was uploaded more than once")
+			continue
+		}
+		if file.Size > config.Values.UploadLimits.MaxFileBytes {
+			report.Errorf(file.Filename, "file has %d bytes, the limit is %d", file.Size, config.Values.UploadLimits.MaxFileBytes)
+			continue
+		}
+
+		content, err := readUploadedFile(file)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		upload[file.Filename] = content
+	}
+
+	validation.ValidateUpload(upload, report)
+	if report.HasErrors() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload validation failed",
+			"files": report.Files,
+		})
+		return nil, false
+	}
+	return upload, true
+}
+
+func readUploadedFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+type uploadResult struct {
+	ChallengeId string             `json:"challengeid"`
+	Revision    int                `json:"revision"`
+	Files       []storage.Artifact `json:"files"`
+	// Flags found in the handout or challenge.yml, the revision cannot be published while there are any
+	Leaks []storage.FlagLeak `json:"leaks"`
+	// Security policy violations in compose.yaml, the revision cannot be published until an admin overrides them
+	PolicyViolations []storage.PolicyViolation `json:"policy_violations"`
+}
+
+// storeRevision saves the validated upload as a new revision of the challenge and makes it the active one
+func storeRevision(ctx context.Context, challengeId, userId string, upload validation.Upload) (uploadResult, error) {
+	result := uploadResult{ChallengeId: challengeId}
+
+	conf, err := storage.ParseChallengeYAML(upload["challenge.yml"])
+	if err != nil {
+		return result, err
+	}
+
+	for _, filename := range allowedFilenames {
+		content, ok := upload[filename]
+		if !ok {
+			continue
+		}
+		digest, size, err := uploads.Digest(bytes.NewReader(content))
+		if err != nil {
+			return result, err
+		}
+		result.Files = append(result.Files, storage.Artifact{Filename: filename, Sha256: digest, Size: size})
+	}
+	result.Leaks = validation.ScanFlagLeaks(upload)
+	result.PolicyViolations = validation.CheckComposePolicy(upload)
+
+	number, err := storage.StoreRevision(storage.NewRevision{
+		ChallengeId:      challengeId,
+		UserId:           userId,
+		Artifacts:        result.Files,
+		Leaks:            result.Leaks,
+		PolicyViolations: result.PolicyViolations,
+		Config:           conf,
+		StoreBlobs: func() error {
+			for _, file := range result.Files {
+				_, _, err := uploads.PutBlob(ctx, bytes.NewReader(upload[file.Filename]))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		if cleanupErr := removeUnreferencedBlobs(ctx, result.Files); cleanupErr != nil {
+			log.Println("Failed to remove blobs of a failed revision: " + cleanupErr.Error())
+		}
+		return result, err
+	}
+	result.Revision = number
+	for i := range result.Files {
+		result.Files[i].ChallengeId = challengeId
+		result.Files[i].Revision = number
+		log.Printf("Uploaded %s of %s revision %d with sha256 %s", result.Files[i].Filename, challengeId, number, result.Files[i].Sha256)
+	}
+	return result, nil
+}
+
+// openArtifact returns the content of a revision file, or uploads.ErrNotFound if it was not uploaded
+func openArtifact(ctx context.Context, challengeId string, revision int, filename string) (io.ReadCloser, int64, storage.Artifact, error) {
+	artifact, err := storage.GetArtifact(challengeId, revision, filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, artifact, uploads.ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, artifact, err
+	}
+
+	reader, size, err := uploads.GetStoreSingleton().Get(ctx, uploads.BlobKey(artifact.Sha256))
+	return reader, size, artifact, err
+}
+
+func readArtifact(ctx context.Context, challengeId string, revision int, filename string) ([]byte, error) {
+	reader, _, _, err := openArtifact(ctx, challengeId, revision, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// readUpload loads the stored files of a revision, as if they had just been uploaded
+func readUpload(ctx context.Context, challengeId string, revision int, filenames ...string) (validation.Upload, error) {
+	upload := validation.Upload{}
+	for _, filename := range filenames {
+		content, err := readArtifact(ctx, challengeId, revision, filename)
+		if errors.Is(err, uploads.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		upload[filename] = content
+	}
+	return upload, nil
+}
+
+func readChallengeConfig(ctx context.Context, challengeId string, revision int) (storage.ChallengeCtfd, error) {
+	content, err := readArtifact(ctx, challengeId, revision, "challenge.yml")
+	if err != nil {
+		return storage.ChallengeCtfd{}, fmt.Errorf("error reading challenge.yml: %v", err)
+	}
+	return storage.ParseChallengeYAML(content)
+}
+
+func activateRevision(ctx context.Context, challengeId string, number int) error {
+	conf, err := readChallengeConfig(ctx, challengeId, number)
+	if err != nil {
+		return err
+	}
+	return storage.ActivateRevision(challengeId, number, conf)
+}
+
+// sendArtifact streams a revision file as an attachment, with its SHA-256 digest in a header
+func sendArtifact(c *gin.Context, challengeId string, revision int, filename string) {
+	reader, size, artifact, err := openArtifact(c, challengeId, revision, filename)
+	if errors.Is(err, uploads.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, size, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, filename),
+		checksumHeader:        artifact.Sha256,
+	})
+}
+
+// removeUnreferencedBlobs deletes blobs that no artifact points to anymore
+func removeUnreferencedBlobs(ctx context.Context, artifacts []storage.Artifact) error {
+	store := uploads.GetStoreSingleton()
+	for _, artifact := range artifacts {
+		err := storage.RemoveUnreferencedBlob(artifact.Sha256, func() error {
+			return store.DeletePrefix(ctx, uploads.BlobKey(artifact.Sha256))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
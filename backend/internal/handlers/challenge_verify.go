@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"fmt"
+	"log"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Longest flag a submission may have, as stored in the submissions table
+const maxFlagLength = 255
+
+// @Summary Verify a player flag
+// @Description Verifies a flag against the dynamic flags of the instances started by the current user
+// @Tags challenges
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Challenge ID"
+// @Param flag body handlers.FlagRequest true "Flag request"
+// @Success 200 {object} handlers.FlagResponse
+// @Failure 400 {object} handlers.ErrorResponse
+// @Router /challenges/{id}/verify [post]
+func VerifyChallengeFlag(c *gin.Context) {
+	challengeId := c.Param("id")
+	userId := auth.GetCurrentUserId(c)
+
+	challenge, err := storage.GetChallengeWrapper(challengeId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+
+	var request FlagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(request.Flag) > maxFlagLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("flag must not be longer than %d characters", maxFlagLength)})
+		return
+	}
+
+	correct, err := storage.VerifyInstanceFlag(challenge.Id, userId, request.Flag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	shared, err := storage.RecordSubmission(challenge.Id, userId, request.Flag, correct)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if shared {
+		log.Printf("Player %s submitted a flag of another player's instance for challenge %s", userId, challenge.Id)
+	}
+
+	if correct {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	} else {
+		quotedFlag := fmt.Sprintf("\"%s\"", request.Flag)
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "failure", "submitted-flag": quotedFlag})
+	}
+}
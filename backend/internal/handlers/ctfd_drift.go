@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"deployer/internal/reconciler"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CtfdDrift godoc
+// @Summary      CTFd drift
+// @Description  Reports differences between the published challenges and CTFd found by the latest reconciliation
+// @Tags         ctfd
+// @Param        refresh	query		bool	false	"Compare with CTFd now instead of returning the latest report"
+// @Accept       json
+// @Produce      json
+// @Router       /ctfd/drift [get]
+// @Security BearerAuth
+func GetCtfdDrift(c *gin.Context) {
+	var report reconciler.Report
+	if c.Query("refresh") == "true" {
+		report = reconciler.Run(false)
+	} else {
+		report = reconciler.LastReport()
+	}
+
+	if report.Error != "" {
+		c.JSON(http.StatusBadGateway, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"deployer/internal/reconciler"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CtfdDriftRepair godoc
+// @Summary      CTFd drift repair
+// @Description  Compares the published challenges with CTFd and repairs the database: challenges deleted in CTFd are unpublished with their player instances stopped, and states changed in CTFd are copied
+// @Tags         ctfd
+// @Accept       json
+// @Produce      json
+// @Router       /ctfd/drift/repair [post]
+// @Security BearerAuth
+func RepairCtfdDrift(c *gin.Context) {
+	report := reconciler.Run(true)
+	if report.Error != "" {
+		c.JSON(http.StatusBadGateway, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"deployer/internal/storage"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLoginFailures = 100
+	maxLoginFailures     = 1000
+)
+
+// LoginFailureList godoc
+// @Summary      Login Failure List
+// @Description  Lists the latest failed logins of local users, newest first
+// @Tags         auth
+// @Param        username	query		string				false	"Username"
+// @Param        limit		query		int					false	"Maximum number of failures, 100 by default"
+// @Produce      json
+// @Router       /auth/login-failures [get]
+// @Security BearerAuth
+func ListLoginFailures(c *gin.Context) {
+	limit := defaultLoginFailures
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxLoginFailures {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxLoginFailures)})
+			return
+		}
+		limit = parsed
+	}
+
+	failures, err := storage.ListLoginFailures(c.Query("username"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if failures == nil {
+		failures = []storage.LoginFailure{}
+	}
+	c.JSON(http.StatusOK, failures)
+}
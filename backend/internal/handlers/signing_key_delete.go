@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SigningKeyDelete godoc
+// @Summary      Signing Key Delete
+// @Description  Removes a retired signing key before its tokens expire, e.g. if it was leaked. The tokens it signed are rejected.
+// @Tags         auth
+// @Param        kid	path		string				true	"Key ID"
+// @Produce      json
+// @Router       /auth/keys/{kid} [delete]
+// @Security BearerAuth
+func DeleteSigningKey(c *gin.Context) {
+	kid := c.Param("kid")
+
+	deleted, err := storage.DeleteSigningKey(kid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Retired key not found. Rotate the keys before deleting the active one."})
+		return
+	}
+	auth.ReloadSigningKeys()
+	log.Printf("Signing key %s deleted", kid)
+
+	c.JSON(http.StatusOK, gin.H{})
+}
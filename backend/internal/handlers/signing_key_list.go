@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SigningKeyResponse struct {
+	auth.SigningKey
+	// Whether the key signs new tokens
+	Active bool `json:"active"`
+	// When the last token signed by a retired key expires and the key is removed
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// SigningKeyList godoc
+// @Summary      Signing Key List
+// @Description  Lists the keys signing the tokens of local users, without their secrets. Without keys, tokens are signed with JWTSECRET.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {array} handlers.SigningKeyResponse
+// @Router       /auth/keys [get]
+// @Security BearerAuth
+func ListSigningKeys(c *gin.Context) {
+	keys, err := storage.ListSigningKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, signingKeyResponses(keys))
+}
+
+func signingKeyResponses(keys []auth.SigningKey) []SigningKeyResponse {
+	result := []SigningKeyResponse{}
+	for _, key := range keys {
+		result = append(result, SigningKeyResponse{SigningKey: key, Active: key.Active(), ValidUntil: key.ValidUntil()})
+	}
+	return result
+}
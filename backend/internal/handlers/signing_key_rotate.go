@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SigningKeyRotate godoc
+// @Summary      Signing Key Rotate
+// @Description  Generates a new key to sign the tokens of local users. The previous key verifies the tokens it signed until they expire.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {array} handlers.SigningKeyResponse
+// @Router       /auth/keys/rotate [post]
+// @Security BearerAuth
+func RotateSigningKey(c *gin.Context) {
+	key, err := auth.NewSigningKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = storage.RotateSigningKey(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	auth.ReloadSigningKeys()
+	log.Printf("Signing key rotated, new kid %s", key.Kid)
+
+	err = storage.DeleteExpiredSigningKeys(time.Now().Add(-auth.AccessTokenLifetime()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys, err := storage.ListSigningKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, signingKeyResponses(keys))
+}
@@ -1,22 +1,20 @@
 package handlers
 
 import (
-	"deployer/config"
 	"deployer/internal/storage"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // @Summary Download solution
-// @Description Downloads a solution
+// @Description Downloads the solution.zip of the revision the test instance was started with
 // @Tags solutions
 // @Param id path string true "Challenge ID"
 // @Param token query string true "Token"
 // @Success 200 {file} file "Challenge file"
+// @Header 200 {string} X-Checksum-Sha256 "SHA-256 digest of the file"
 // @Failure 401 {object} handlers.ErrorResponse "Unauthorized"
 // @Failure 404 {object} handlers.ErrorResponse "File not found"
 // @Router /solutions/{id}/download [get]
@@ -30,12 +28,11 @@ func DownloadSolution(c *gin.Context) {
 		return
 	}
 
-	file := filepath.Join(config.Values.UploadPath, challengeId, "solution.zip")
-	_, err = os.Stat(file)
+	challenge, err := storage.GetChallenge(challengeId)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
 		return
 	}
 
-	c.FileAttachment(file, "solution.zip")
+	sendArtifact(c, challenge.Id, instanceRevision(instance, challenge), "solution.zip")
 }
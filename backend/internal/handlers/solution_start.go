@@ -57,7 +57,7 @@ func StartTest(c *gin.Context) {
 		return
 	}
 
-	instanceId, token, err := storage.CreateInstance(userId, challenge.Id)
+	instance, err := storage.CreateInstance(userId, challenge.Id, challenge.Revision)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -65,7 +65,7 @@ func StartTest(c *gin.Context) {
 
 	testMode := true
 	challengeDomain := getChallengeDomain(runningIdChallenge)
-	res, err := createResources(c, userId, &challenge, instanceId, token, challengeDomain, testMode)
+	res, err := createResources(c, userId, &challenge, &instance, challengeDomain, testMode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -59,14 +59,28 @@ func VerifyFlag(c *gin.Context) {
 	}
 
 	flag := json.Flag
-	expectedFlag, err := storage.GetChallengeFlag(challengeId)
+	correct, err := storage.VerifyChallengeFlag(challengeId, flag)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if flag == expectedFlag {
+	// The solution may have captured the dynamic flag of the challenge instance it ran against
+	if !correct {
+		testInstance, err := storage.GetInstanceById(runningIdTest)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		correct, err = storage.VerifyInstanceFlag(challengeId, testInstance.PlayerId, flag)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if correct {
 		if err := storage.MarkChallengeVerified(challengeId); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"deployer/internal/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmissionShared godoc
+// @Summary      Shared flag submissions
+// @Description  Lists submissions of flags generated for the instance of another player
+// @Tags         submissions
+// @Accept       json
+// @Produce      json
+// @Router       /submissions/shared [get]
+// @Security BearerAuth
+func ListSharedSubmissions(c *gin.Context) {
+	res, err := storage.ListSharedSubmissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"submissions": res,
+	})
+}
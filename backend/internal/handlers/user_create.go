@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const minPasswordLength = 8
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// admin or developer
+	Role string `json:"role"`
+}
+
+// UserCreate godoc
+// @Summary      User Create
+// @Description  Creates a local user
+// @Tags         users
+// @Param        user	body		CreateUserRequest	true	"User"
+// @Accept       json
+// @Produce      json
+// @Router       /users [post]
+// @Security BearerAuth
+func CreateUser(c *gin.Context) {
+	var request CreateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request.Username = strings.TrimSpace(request.Username)
+	if request.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username is required"})
+		return
+	}
+	if err := checkRole(request.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkPassword(request.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := storage.CreateUser(request.Username, hash, request.Role)
+	if errors.Is(err, storage.ErrUserExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func checkRole(role string) error {
+	if !slices.Contains(auth.Roles, role) {
+		return fmt.Errorf("role must be one of %s", strings.Join(auth.Roles, ", "))
+	}
+	return nil
+}
+
+func checkPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must have at least %d characters", minPasswordLength)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserDelete godoc
+// @Summary      User Delete
+// @Description  Deletes a local user. Challenges of the user are kept.
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Produce      json
+// @Router       /users/{id} [delete]
+// @Security BearerAuth
+func DeleteUser(c *gin.Context) {
+	user, ok := getUser(c)
+	if !ok {
+		return
+	}
+	if user.Id == auth.GetCurrentUserId(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot delete your own account"})
+		return
+	}
+
+	err := storage.DeleteUser(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s deleted", user.Username)
+
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserDisable godoc
+// @Summary      User Disable
+// @Description  Disables a local user. The user cannot log in and existing tokens are rejected.
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Produce      json
+// @Router       /users/{id}/disable [post]
+// @Security BearerAuth
+func DisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+// UserEnable godoc
+// @Summary      User Enable
+// @Description  Enables a disabled local user
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Produce      json
+// @Router       /users/{id}/enable [post]
+// @Security BearerAuth
+func EnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+func setUserDisabled(c *gin.Context, disabled bool) {
+	user, ok := getUser(c)
+	if !ok {
+		return
+	}
+	if disabled && user.Id == auth.GetCurrentUserId(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot disable your own account"})
+		return
+	}
+
+	err := storage.SetUserDisabled(user.Id, disabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s disabled: %t", user.Username, disabled)
+
+	user.Disabled = disabled
+	c.JSON(http.StatusOK, user)
+}
+
+// getUser returns the user of the id parameter. If it fails, the error response has been sent.
+func getUser(c *gin.Context) (storage.User, bool) {
+	user, err := storage.GetUserById(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not found",
+		})
+		return user, false
+	}
+	return user, true
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"deployer/internal/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserList godoc
+// @Summary      User List
+// @Description  Lists the local users
+// @Tags         users
+// @Produce      json
+// @Router       /users [get]
+// @Security BearerAuth
+func ListUsers(c *gin.Context) {
+	users, err := storage.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if users == nil {
+		users = []storage.User{}
+	}
+	c.JSON(http.StatusOK, users)
+}
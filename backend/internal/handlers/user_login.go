@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
+	"deployer/config"
 	"deployer/internal/auth"
 	"deployer/internal/storage"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -15,12 +21,36 @@ type LoginRequest struct {
 	Password string
 }
 
+// TokenResponse is returned by logging in and refreshing
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// Seconds until the token expires
+	ExpiresIn int `json:"expires_in"`
+}
+
+// TotpChallengeResponse is returned by logging in when the user has to complete the login with /users/login/totp
+type TotpChallengeResponse struct {
+	TotpRequired bool `json:"totp_required"`
+	// The user has to enroll TOTP with /users/login/totp/setup first, as it is required for the role
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	LoginToken         string `json:"login_token"`
+	// Seconds until the login token expires
+	ExpiresIn int `json:"expires_in"`
+}
+
 // UserLogin godoc
 // @Summary      User Login
+// @Description  Returns a short-lived access token and a refresh token, which gets new access tokens with /users/refresh.
+// @Description  Repeated failures make further logins of the username or from the IP wait, up to a temporary lockout.
+// @Description  Users with TOTP get a login token instead, to complete the login with /users/login/totp.
 // @Tags         users
 // @Param			login	body		LoginRequest			true	"Credentials"
 // @Accept       json
 // @Produce      json
+// @Success      200  {object}  TokenResponse
+// @Failure      401  {object}  handlers.ErrorResponse "Invalid username or password"
+// @Failure      429  {object}  handlers.ErrorResponse "Too many failed logins"
 // @Router       /users/login [post]
 func Login(c *gin.Context) {
 	var request LoginRequest
@@ -29,21 +59,182 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+	attempt, ok := reserveLoginAttempt(c, request.Username, ip)
+	if !ok {
+		return
+	}
+
 	user, err := storage.GetUser(request.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Compare anyway, so unknown usernames take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(request.Password))
+		loginFailed(c, attempt, auth.LoginUnknownUser)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		attempt.release()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.Password))
 	if err != nil {
-		log.Println("Wrong password")
-		c.JSON(http.StatusUnauthorized, gin.H{})
+		loginFailed(c, attempt, auth.LoginWrongPassword)
+		return
+	}
+	if user.Disabled {
+		loginFailed(c, attempt, auth.LoginDisabled)
 		return
 	}
+	attempt.release()
+
+	// Failures are only forgotten once the TOTP code is right too, so a known password does not allow guessing more codes
+	if user.TotpEnabled || auth.TotpRequired(user.Role) {
+		loginToken, tokenHash, err := auth.NewLoginToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		err = storage.CreateLoginChallenge(user.Id, tokenHash, time.Now().Add(auth.LoginTokenLifetime))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, TotpChallengeResponse{
+			TotpRequired:       true,
+			EnrollmentRequired: !user.TotpEnabled,
+			LoginToken:         loginToken,
+			ExpiresIn:          int(auth.LoginTokenLifetime.Seconds()),
+		})
+		return
+	}
+
+	if response, ok := startSession(c, user, ip); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// startSession logs the user in with a new session. If it fails, the error response has been sent.
+func startSession(c *gin.Context, user storage.User, ip string) (TokenResponse, bool) {
+	userKey, _ := auth.LoginThrottleKeys(user.Username, ip)
+	err := storage.ResetLoginFailures(userKey)
+	if err != nil {
+		log.Println("Failed to reset failed logins: " + err.Error())
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TokenResponse{}, false
+	}
+	sessionId, err := storage.CreateSession(user.Id, refreshHash, time.Now().Add(auth.RefreshTokenLifetime()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TokenResponse{}, false
+	}
+	return newTokenResponse(c, user.Id, user.Role, sessionId, refreshToken)
+}
+
+// newTokenResponse issues a new access token for the session. If it fails, the error response has been sent.
+func newTokenResponse(c *gin.Context, userId, role, sessionId, refreshToken string) (TokenResponse, bool) {
+	token, err := auth.CreateToken(userId, role, sessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TokenResponse{}, false
+	}
+	return TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenLifetime().Seconds()),
+	}, true
+}
+
+// loginAttempt is a login of a username from an IP that is counted as failed before the password or code is checked,
+// so concurrent logins cannot try more than the throttle allows. It is released again if the check passes.
+type loginAttempt struct {
+	username string
+	ip       string
+	// Throttle keys the attempt was counted for, with the lock it set or nil
+	reserved map[string]*time.Time
+}
+
+// reserveLoginAttempt counts the attempt for the username and the IP, and locks them right away if they failed too often.
+// It responds with 429 and returns false if logins of the username or from the IP have to wait.
+func reserveLoginAttempt(c *gin.Context, username, ip string) (loginAttempt, bool) {
+	attempt := loginAttempt{username: username, ip: ip, reserved: map[string]*time.Time{}}
+	limits := config.Values.LoginThrottle
+	userKey, ipKey := auth.LoginThrottleKeys(username, ip)
+	forgetBefore := time.Now().Add(-auth.LoginForgetAfter())
+	for _, key := range []string{userKey, ipKey} {
+		freeAttempts := limits.FreeAttempts
+		if key == ipKey {
+			freeAttempts = limits.IpFreeAttempts
+		}
+
+		failures, until, err := storage.ReserveLoginAttempt(key, forgetBefore)
+		if err != nil {
+			attempt.release()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return attempt, false
+		}
+		if until != nil {
+			attempt.release()
+			log.Printf("Login of %s from %s locked until %s", username, ip, until.Format(time.RFC3339))
+			auditLoginFailure(username, ip, auth.LoginLocked)
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(*until).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed logins, try again later"})
+			return attempt, false
+		}
+
+		attempt.reserved[key] = nil
+		if backoff := auth.LoginBackoff(failures, freeAttempts); backoff > 0 {
+			// Microseconds like the database, so releasing finds the lock again
+			lockedUntil := time.Now().Add(backoff).Truncate(time.Microsecond)
+			err = storage.LockLogin(key, lockedUntil)
+			if err != nil {
+				log.Println("Failed to lock login: " + err.Error())
+				continue
+			}
+			attempt.reserved[key] = &lockedUntil
+		}
+	}
+	return attempt, true
+}
+
+// release takes back the attempt, as it did not fail
+func (a loginAttempt) release() {
+	for key, lockedUntil := range a.reserved {
+		err := storage.ReleaseLoginAttempt(key, lockedUntil)
+		if err != nil {
+			log.Println("Failed to release login attempt: " + err.Error())
+		}
+	}
+}
+
+// dummyPasswordHash is compared with the password of unknown usernames
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return hash
+})
 
-	token := auth.CreateToken(user.Id, user.Role)
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-	})
+// loginFailed records the failure and responds the same for every reason, so callers do not learn whether the username exists
+func loginFailed(c *gin.Context, attempt loginAttempt, reason string) {
+	attempt.failed(reason)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+}
+
+// failed records the failure. It was already counted when the attempt was reserved.
+func (a loginAttempt) failed(reason string) {
+	log.Printf("Login of %s from %s failed: %s", a.username, a.ip, reason)
+	auditLoginFailure(a.username, a.ip, reason)
+}
+
+func auditLoginFailure(username, ip, reason string) {
+	now := time.Now()
+	retention := time.Duration(config.Values.LoginThrottle.AuditRetentionDays) * 24 * time.Hour
+	err := storage.AddLoginFailure(username, ip, reason, now.Add(-retention), now.Add(-auth.LoginForgetAfter()))
+	if err != nil {
+		log.Println("Failed to record failed login: " + err.Error())
+	}
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginTokenRequest struct {
+	LoginToken string `json:"login_token" binding:"required"`
+}
+
+type TotpLoginRequest struct {
+	LoginToken string `json:"login_token" binding:"required"`
+	TotpCodeRequest
+}
+
+// TotpLoginResponse is returned by completing a login, with the recovery codes if TOTP was enrolled
+type TotpLoginResponse struct {
+	TokenResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// UserLoginTotp godoc
+// @Summary      User Login TOTP
+// @Description  Completes a login with the login token and a TOTP or recovery code. If TOTP is required but not enrolled yet,
+// @Description  the code of the secret from /users/login/totp/setup enables it and the recovery codes are returned.
+// @Tags         users
+// @Param        login	body		TotpLoginRequest			true	"Login token and code"
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  TotpLoginResponse
+// @Router       /users/login/totp [post]
+func LoginTotp(c *gin.Context) {
+	var request TotpLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokenHash := auth.HashToken(request.LoginToken)
+	user, ok := getLoginChallenge(c, tokenHash)
+	if !ok {
+		return
+	}
+
+	var response TotpLoginResponse
+	if user.TotpEnabled {
+		if !checkSecondFactor(c, user, request.TotpCodeRequest) {
+			return
+		}
+	} else {
+		response.RecoveryCodes, ok = enrollTotp(c, user, request.Code)
+		if !ok {
+			return
+		}
+	}
+
+	// The login token is only used once, also if the same code is sent twice at the same time
+	ok, err := storage.DeleteLoginChallenge(tokenHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+
+	response.TokenResponse, ok = startSession(c, user, c.ClientIP())
+	if ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// UserLoginTotpSetup godoc
+// @Summary      User Login TOTP Setup
+// @Description  Starts the TOTP enrollment during a login of a user who has to use TOTP but has not enrolled yet
+// @Tags         users
+// @Param        login	body		LoginTokenRequest			true	"Login token"
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  TotpSetupResponse
+// @Router       /users/login/totp/setup [post]
+func LoginTotpSetup(c *gin.Context) {
+	var request LoginTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := getLoginChallenge(c, auth.HashToken(request.LoginToken))
+	if !ok {
+		return
+	}
+	setupTotp(c, user)
+}
+
+// getLoginChallenge returns the user of a login waiting for its TOTP code. If it fails, the error response has been sent.
+func getLoginChallenge(c *gin.Context, tokenHash string) (storage.User, bool) {
+	user, err := storage.GetLoginChallenge(tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return user, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return user, false
+	}
+	if user.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return user, false
+	}
+	return user, true
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserLogout godoc
+// @Summary      User Logout
+// @Description  Ends the session of the access token: its refresh token and the access token itself are no longer accepted
+// @Tags         users
+// @Produce      json
+// @Router       /users/logout [post]
+// @Security BearerAuth
+func Logout(c *gin.Context) {
+	sessionId, jti, expiresAt := auth.GetCurrentSession(c)
+	if jti == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only tokens of local users can log out"})
+		return
+	}
+
+	userId := auth.GetCurrentUserId(c)
+	if sessionId != "" {
+		err := storage.RevokeSession(sessionId, userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	err := storage.RevokeToken(jti, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s logged out", userId)
+
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"encoding/base64"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetRequest struct {
+	// A random password is generated and returned if omitted
+	Password string `json:"password"`
+}
+
+// UserPasswordReset godoc
+// @Summary      User Password Reset
+// @Description  Sets a new password for a local user. Existing tokens of the user are rejected.
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Param        password	body		PasswordResetRequest	false	"New password"
+// @Accept       json
+// @Produce      json
+// @Router       /users/{id}/password [post]
+// @Security BearerAuth
+func ResetUserPassword(c *gin.Context) {
+	var request PasswordResetRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, ok := getUser(c)
+	if !ok {
+		return
+	}
+
+	generated := request.Password == ""
+	if generated {
+		random := make([]byte, 18)
+		if _, err := rand.Read(random); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		request.Password = base64.RawURLEncoding.EncodeToString(random)
+	}
+	if err := checkPassword(request.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = storage.SetUserPassword(user.Id, hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Password of user %s reset", user.Username)
+
+	if generated {
+		c.JSON(http.StatusOK, gin.H{"password": request.Password})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
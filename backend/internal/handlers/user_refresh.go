@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// UserRefresh godoc
+// @Summary      User Token Refresh
+// @Description  Returns a new access token for the session of the refresh token. The refresh token is replaced by the one returned;
+// @Description  using a replaced refresh token again revokes the session. The session expires at the time set at login.
+// @Description  Sessions of users whose role requires TOTP are revoked until they have enrolled.
+// @Tags         users
+// @Param        refresh	body		RefreshRequest			true	"Refresh token"
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  TokenResponse
+// @Router       /users/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var request RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	session, err := storage.RefreshSession(auth.HashToken(request.RefreshToken), refreshHash)
+	if errors.Is(err, storage.ErrSessionInvalid) {
+		log.Println("Invalid refresh token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The role may require TOTP since logging in, the user has to enroll with the next login
+	if auth.TotpRequired(session.Role) && !session.TotpEnabled {
+		err = storage.RevokeSession(session.Id, session.UserId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "TOTP is required for your role, log in again to enroll"})
+		return
+	}
+
+	if response, ok := newTokenResponse(c, session.UserId, session.Role, session.Id, refreshToken); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserRoleRequest struct {
+	// admin or developer
+	Role string `json:"role"`
+}
+
+// UserRole godoc
+// @Summary      User Role
+// @Description  Changes the role of a local user. The user has to log in again.
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Param        role	body		UserRoleRequest	true	"Role"
+// @Accept       json
+// @Produce      json
+// @Router       /users/{id}/role [put]
+// @Security BearerAuth
+func SetUserRole(c *gin.Context) {
+	var request UserRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkRole(request.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := getUser(c)
+	if !ok {
+		return
+	}
+	if user.Id == auth.GetCurrentUserId(c) && request.Role != user.Role {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change the role of your own account"})
+		return
+	}
+
+	err := storage.SetUserRole(user.Id, request.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s has role %s", user.Username, request.Role)
+
+	user.Role = request.Role
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserSessionsRevoke godoc
+// @Summary      User Sessions Revoke
+// @Description  Logs a local user out everywhere, e.g. after a lost laptop. Refresh tokens and access tokens issued before are rejected.
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Produce      json
+// @Router       /users/{id}/sessions/revoke [post]
+// @Security BearerAuth
+func RevokeUserSessions(c *gin.Context) {
+	user, ok := getUser(c)
+	if !ok {
+		return
+	}
+
+	count, err := storage.RevokeUserSessions(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Revoked %d sessions of user %s", count, user.Username)
+
+	c.JSON(http.StatusOK, gin.H{"revoked": count})
+}
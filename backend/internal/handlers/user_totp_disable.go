@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserTotpDisable godoc
+// @Summary      User TOTP Disable
+// @Description  Disables TOTP for the current local user, unless it is required for the role
+// @Tags         users
+// @Param        code	body		TotpCodeRequest			true	"TOTP or recovery code"
+// @Accept       json
+// @Produce      json
+// @Router       /users/me/totp [delete]
+// @Security BearerAuth
+func DisableTotp(c *gin.Context) {
+	var request TotpCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := getCurrentLocalUser(c)
+	if !ok {
+		return
+	}
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TOTP is not enabled"})
+		return
+	}
+	if auth.TotpRequired(user.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TOTP is required for the " + user.Role + " role"})
+		return
+	}
+	if !checkSecondFactor(c, user, request) {
+		return
+	}
+
+	err := storage.DisableTotp(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s disabled TOTP", user.Username)
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// UserTotpReset godoc
+// @Summary      User TOTP Reset
+// @Description  Disables TOTP for a local user who lost the authenticator app and the recovery codes.
+// @Description  If TOTP is required for the role, the user enrolls again at the next login.
+// @Tags         users
+// @Param        id	path		string				true	"User ID"
+// @Produce      json
+// @Router       /users/{id}/totp [delete]
+// @Security BearerAuth
+func ResetUserTotp(c *gin.Context) {
+	user, ok := getUser(c)
+	if !ok {
+		return
+	}
+
+	err := storage.DisableTotp(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("TOTP of user %s reset", user.Username)
+
+	user.TotpEnabled = false
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserTotpEnable godoc
+// @Summary      User TOTP Enable
+// @Description  Enables TOTP for the current local user with a code of the secret from /users/me/totp, and returns the recovery codes.
+// @Description  Logins need a TOTP code afterwards.
+// @Tags         users
+// @Param        code	body		TotpCodeRequest			true	"TOTP code"
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  RecoveryCodesResponse
+// @Router       /users/me/totp/enable [post]
+// @Security BearerAuth
+func EnableTotp(c *gin.Context) {
+	var request TotpCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := getCurrentLocalUser(c)
+	if !ok {
+		return
+	}
+	if user.TotpEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "TOTP is already enabled"})
+		return
+	}
+
+	codes, ok := enrollTotp(c, user, request.Code)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserTotpRecoveryCodes godoc
+// @Summary      User TOTP Recovery Codes
+// @Description  Replaces the recovery codes of the current local user, e.g. when most are used
+// @Tags         users
+// @Param        code	body		TotpCodeRequest			true	"TOTP or recovery code"
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  RecoveryCodesResponse
+// @Router       /users/me/totp/recovery-codes [post]
+// @Security BearerAuth
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var request TotpCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := getCurrentLocalUser(c)
+	if !ok {
+		return
+	}
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TOTP is not enabled"})
+		return
+	}
+	if !checkSecondFactor(c, user, request) {
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = storage.ReplaceRecoveryCodes(user.Id, hashes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("User %s replaced the recovery codes", user.Username)
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
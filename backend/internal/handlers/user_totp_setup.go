@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"deployer/internal/auth"
+	"deployer/internal/storage"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TotpSetupResponse struct {
+	Secret string `json:"secret"`
+	// otpauth:// URI to show as QR code to the authenticator app
+	ProvisioningUri string `json:"provisioning_uri"`
+}
+
+// TotpCodeRequest proves the second factor with a TOTP code or, without an authenticator app, a recovery code
+type TotpCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type RecoveryCodesResponse struct {
+	// Each code replaces a TOTP code once. They are only shown now.
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// UserTotpSetup godoc
+// @Summary      User TOTP Setup
+// @Description  Starts the TOTP enrollment of the current local user with a new secret. Enable it with /users/me/totp/enable.
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  TotpSetupResponse
+// @Router       /users/me/totp [post]
+// @Security BearerAuth
+func SetupTotp(c *gin.Context) {
+	user, ok := getCurrentLocalUser(c)
+	if !ok {
+		return
+	}
+	setupTotp(c, user)
+}
+
+// setupTotp stores a new pending secret for the user and responds with it
+func setupTotp(c *gin.Context, user storage.User) {
+	secret, err := auth.NewTotpSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ok, err := storage.SetTotpSecret(user.Id, secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "TOTP is already enabled"})
+		return
+	}
+	log.Printf("User %s started the TOTP enrollment", user.Username)
+
+	c.JSON(http.StatusOK, TotpSetupResponse{
+		Secret:          secret,
+		ProvisioningUri: auth.TotpProvisioningUri(user.Username, secret),
+	})
+}
+
+// enrollTotp enables the pending TOTP secret of the user if the code is right, and returns the new recovery codes.
+// If it fails, the error response has been sent.
+func enrollTotp(c *gin.Context, user storage.User, code string) ([]string, bool) {
+	if user.TotpSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set up TOTP first"})
+		return nil, false
+	}
+	attempt, reserved := reserveLoginAttempt(c, user.Username, c.ClientIP())
+	if !reserved {
+		return nil, false
+	}
+	step, ok := auth.ValidateTotp(user.TotpSecret, code, time.Now())
+	if !ok {
+		codeFailed(c, attempt)
+		return nil, false
+	}
+	attempt.release()
+
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	err = storage.EnableTotp(user.Id, step, hashes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	log.Printf("User %s enabled TOTP", user.Username)
+	return codes, true
+}
+
+// checkSecondFactor verifies the TOTP or recovery code of a user with TOTP enabled. Each code is only accepted once.
+// If it fails, the error response has been sent.
+func checkSecondFactor(c *gin.Context, user storage.User, request TotpCodeRequest) bool {
+	attempt, reserved := reserveLoginAttempt(c, user.Username, c.ClientIP())
+	if !reserved {
+		return false
+	}
+
+	var ok bool
+	var err error
+	if request.RecoveryCode != "" {
+		ok, err = storage.UseRecoveryCode(user.Id, auth.HashRecoveryCode(request.RecoveryCode))
+		if ok {
+			log.Printf("User %s used a recovery code", user.Username)
+		}
+	} else if step, valid := auth.ValidateTotp(user.TotpSecret, request.Code, time.Now()); valid {
+		ok, err = storage.UseTotpStep(user.Id, step)
+	}
+	if err != nil {
+		attempt.release()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !ok {
+		codeFailed(c, attempt)
+		return false
+	}
+	attempt.release()
+	return true
+}
+
+// codeFailed records a wrong code. It was counted like a failed login when the attempt was reserved, so codes cannot be guessed.
+func codeFailed(c *gin.Context, attempt loginAttempt) {
+	attempt.failed(auth.LoginWrongCode)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+}
+
+// getCurrentLocalUser returns the local user of the token, not an API key. If it fails, the error response has been sent.
+func getCurrentLocalUser(c *gin.Context) (storage.User, bool) {
+	if auth.IsApiKey(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot change TOTP"})
+		return storage.User{}, false
+	}
+	user, err := storage.GetUserById(auth.GetCurrentUserId(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not found",
+		})
+		return user, false
+	}
+	return user, true
+}
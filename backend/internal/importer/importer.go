@@ -0,0 +1,193 @@
+package importer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"deployer/internal/validation"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directories of a challenge in a ctfcli-style repository and the upload file each one is packed into
+var directoryArchives = map[string]string{
+	"src":      "challenge.zip",
+	"solution": "solution.zip",
+	"handout":  "handout.zip",
+}
+
+// modified is the time stored in every zip entry, so unchanged directories give identical archives
+var modified = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var ErrRepositoryTooLarge = errors.New("repository is too large")
+
+// Source is one challenge of the repository, packed like an upload
+type Source struct {
+	Dir    string
+	Upload validation.Upload
+}
+
+type file struct {
+	mode    fs.FileMode
+	content []byte
+}
+
+// ReadRepository reads a tar archive, optionally gzip compressed, of a ctfcli-style repository.
+// Every directory with a challenge.yml is a challenge: its src, solution and handout directories
+// are packed into challenge.zip, solution.zip and handout.zip.
+func ReadRepository(r io.Reader, limit int64) ([]Source, error) {
+	files, err := readTar(r, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for name := range files {
+		if path.Base(name) == "challenge.yml" {
+			dirs = append(dirs, path.Dir(name))
+		}
+	}
+	sort.Strings(dirs)
+
+	var sources []Source
+	for _, dir := range dirs {
+		// Challenges nested in another challenge, e.g. an example in src, belong to the outer one
+		if nested(dir, sources) {
+			continue
+		}
+		upload := validation.Upload{"challenge.yml": files[path.Join(dir, "challenge.yml")].content}
+		for directory, filename := range directoryArchives {
+			content, ok, err := packDirectory(files, path.Join(dir, directory))
+			if err != nil {
+				return nil, fmt.Errorf("error packing %s: %v", path.Join(dir, directory), err)
+			}
+			if ok {
+				upload[filename] = content
+			}
+		}
+		sources = append(sources, Source{Dir: dir, Upload: upload})
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no challenge.yml found in the repository")
+	}
+	return sources, nil
+}
+
+// readTar returns the regular files of the archive by their cleaned path. Other entries are skipped.
+func readTar(r io.Reader, limit int64) (map[string]file, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid tar archive: %v", err)
+	}
+	var stream io.Reader = reader
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid gzip archive: %v", err)
+		}
+		defer gz.Close()
+		stream = gz
+	}
+
+	files := map[string]file{}
+	var total int64
+	archive := tar.NewReader(stream)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a valid tar archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if unsafeName(header.Name) {
+			return nil, fmt.Errorf("entry '%s' points outside the repository", header.Name)
+		}
+
+		total += header.Size
+		if total > limit {
+			return nil, ErrRepositoryTooLarge
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(header.Name)] = file{mode: header.FileInfo().Mode(), content: content}
+	}
+	return files, nil
+}
+
+func unsafeName(name string) bool {
+	if strings.Contains(name, `\`) || strings.HasPrefix(name, "/") {
+		return true
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func nested(dir string, sources []Source) bool {
+	for _, source := range sources {
+		if isWithin(dir, source.Dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWithin(name, dir string) bool {
+	return dir == "." || strings.HasPrefix(name, dir+"/")
+}
+
+// packDirectory zips the files below dir with paths relative to it. It returns false if there are none.
+// Entries are sorted and have a fixed time, so the same files always give the same archive.
+func packDirectory(files map[string]file, dir string) ([]byte, bool, error) {
+	var names []string
+	for name := range files {
+		if isWithin(name, dir) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, false, nil
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, name := range names {
+		header := &zip.FileHeader{
+			Name:     strings.TrimPrefix(name, dir+"/"),
+			Method:   zip.Deflate,
+			Modified: modified,
+		}
+		header.SetMode(files[name].mode)
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return nil, false, err
+		}
+		_, err = entry.Write(files[name].content)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false, err
+	}
+	return buffer.Bytes(), true, nil
+}
@@ -0,0 +1,263 @@
+package importer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	content  string
+	typeflag byte
+}
+
+func buildTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	for _, entry := range entries {
+		typeflag := entry.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		header := &tar.Header{Name: entry.name, Typeflag: typeflag, Mode: 0o644}
+		if typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.content))
+		}
+		if typeflag == tar.TypeSymlink {
+			header.Linkname = entry.content
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if typeflag == tar.TypeReg {
+			if _, err := writer.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// zipContents returns the files of a packed directory by name
+func zipContents(t *testing.T, content []byte) map[string]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]string{}
+	for _, entry := range reader.File {
+		file, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[entry.Name] = string(data)
+	}
+	return result
+}
+
+func uploadNames(source Source) []string {
+	var names []string
+	for name := range source.Upload {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReadRepository(t *testing.T) {
+	repository := buildTar(t,
+		tarEntry{name: "web/login/challenge.yml", content: "name: login"},
+		tarEntry{name: "web/login/src/compose.yaml", content: "services: {}"},
+		tarEntry{name: "web/login/src/app/main.py", content: "print()"},
+		tarEntry{name: "web/login/solution/solve.py", content: "solve()"},
+		tarEntry{name: "web/login/src/example/challenge.yml", content: "name: nested"},
+		tarEntry{name: "pwn/heap/challenge.yml", content: "name: heap"},
+		tarEntry{name: "pwn/heap/handout/heap", content: "ELF"},
+		tarEntry{name: "pwn/heap/src", typeflag: tar.TypeDir},
+		tarEntry{name: "pwn/heap/link", content: "/etc/passwd", typeflag: tar.TypeSymlink},
+		tarEntry{name: "README.md", content: "challenges"},
+	)
+
+	for name, content := range map[string][]byte{"tar": repository, "tar.gz": gzipped(t, repository)} {
+		t.Run(name, func(t *testing.T) {
+			sources, err := ReadRepository(bytes.NewReader(content), 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sources) != 2 {
+				t.Fatalf("got %d challenges, want 2: %+v", len(sources), sources)
+			}
+
+			heap, login := sources[0], sources[1]
+			if heap.Dir != "pwn/heap" || login.Dir != "web/login" {
+				t.Fatalf("directories = %s, %s", heap.Dir, login.Dir)
+			}
+
+			if names := uploadNames(heap); !reflect.DeepEqual(names, []string{"challenge.yml", "handout.zip"}) {
+				t.Errorf("pwn/heap upload = %v", names)
+			}
+			if got := zipContents(t, heap.Upload["handout.zip"]); !reflect.DeepEqual(got, map[string]string{"heap": "ELF"}) {
+				t.Errorf("pwn/heap handout.zip = %v", got)
+			}
+
+			if names := uploadNames(login); !reflect.DeepEqual(names, []string{"challenge.yml", "challenge.zip", "solution.zip"}) {
+				t.Errorf("web/login upload = %v", names)
+			}
+			if string(login.Upload["challenge.yml"]) != "name: login" {
+				t.Errorf("web/login challenge.yml = %q", login.Upload["challenge.yml"])
+			}
+			want := map[string]string{
+				"compose.yaml":          "services: {}",
+				"app/main.py":           "print()",
+				"example/challenge.yml": "name: nested",
+			}
+			if got := zipContents(t, login.Upload["challenge.zip"]); !reflect.DeepEqual(got, want) {
+				t.Errorf("web/login challenge.zip = %v", got)
+			}
+		})
+	}
+}
+
+func TestReadRepositoryIsDeterministic(t *testing.T) {
+	first := buildTar(t,
+		tarEntry{name: "a/challenge.yml", content: "name: a"},
+		tarEntry{name: "a/src/one", content: "1"},
+		tarEntry{name: "a/src/two", content: "2"},
+	)
+	second := buildTar(t,
+		tarEntry{name: "a/src/two", content: "2"},
+		tarEntry{name: "./a/src/one", content: "1"},
+		tarEntry{name: "a/challenge.yml", content: "name: a"},
+	)
+
+	a, err := ReadRepository(bytes.NewReader(first), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadRepository(bytes.NewReader(second), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a[0].Upload["challenge.zip"], b[0].Upload["challenge.zip"]) {
+		t.Error("the same files in another order gave a different challenge.zip")
+	}
+}
+
+func TestReadRepositoryRootChallenge(t *testing.T) {
+	repository := buildTar(t,
+		tarEntry{name: "challenge.yml", content: "name: root"},
+		tarEntry{name: "src/compose.yaml", content: "services: {}"},
+		tarEntry{name: "other/challenge.yml", content: "name: other"},
+	)
+
+	sources, err := ReadRepository(bytes.NewReader(repository), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].Dir != "." {
+		t.Fatalf("sources = %+v, want only the root challenge", sources)
+	}
+}
+
+func TestReadRepositoryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		limit   int64
+		want    string
+	}{
+		{
+			name:    "empty",
+			content: nil,
+			limit:   1 << 20,
+			want:    "not a valid tar archive",
+		},
+		{
+			name:    "not a tar archive",
+			content: []byte("this is not a tar archive, just some text that is long enough"),
+			limit:   1 << 20,
+			want:    "not a valid tar archive",
+		},
+		{
+			name:    "no challenge",
+			content: buildTar(t, tarEntry{name: "README.md", content: "nothing here"}),
+			limit:   1 << 20,
+			want:    "no challenge.yml found",
+		},
+		{
+			name:    "parent directory",
+			content: buildTar(t, tarEntry{name: "a/../../challenge.yml", content: "name: a"}),
+			limit:   1 << 20,
+			want:    "points outside the repository",
+		},
+		{
+			name:    "absolute path",
+			content: buildTar(t, tarEntry{name: "/etc/challenge.yml", content: "name: a"}),
+			limit:   1 << 20,
+			want:    "points outside the repository",
+		},
+		{
+			name:    "backslash",
+			content: buildTar(t, tarEntry{name: `a\..\challenge.yml`, content: "name: a"}),
+			limit:   1 << 20,
+			want:    "points outside the repository",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadRepository(bytes.NewReader(test.content), test.limit)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestReadRepositoryLimit(t *testing.T) {
+	repository := buildTar(t,
+		tarEntry{name: "a/challenge.yml", content: "name: a"},
+		tarEntry{name: "a/src/data", content: strings.Repeat("x", 100)},
+	)
+
+	_, err := ReadRepository(bytes.NewReader(repository), 100)
+	if !errors.Is(err, ErrRepositoryTooLarge) {
+		t.Errorf("error = %v, want ErrRepositoryTooLarge", err)
+	}
+
+	_, err = ReadRepository(bytes.NewReader(repository), 107)
+	if err != nil {
+		t.Errorf("repository exactly at the limit: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package infrastructure
 
 import (
+	"context"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -69,6 +70,34 @@ func GetNumberChallengesRunningForUserId(c *gin.Context, userId string) (int, er
 	return len(nsList.Items), nil
 }
 
+// StopPlayerInstances deletes the namespaces of every player instance of the challenge, leaving test instances running
+func StopPlayerInstances(ctx context.Context, challengeId string) (int, error) {
+	kubeconfig := GetKubeConfigSingleton()
+	clientset, err := kubernetes.NewForConfig(kubeconfig)
+	if err != nil {
+		return 0, err
+	}
+	nsList, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: namespaceLabelChallengeId + "=" + challengeId + "," + testLabel + "=false",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	stopped := 0
+	for _, ns := range nsList.Items {
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		err = clientset.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return stopped, err
+		}
+		stopped++
+	}
+	return stopped, nil
+}
+
 func BuildNamespace(challengeId, instanceid, playerId string, testMode bool) *corev1.Namespace {
 
 	var name string
@@ -19,7 +19,7 @@ const labelName = "custom-challenge-selector"
 // TODO add volume for docker-compose or fix authentication for wget /download endpoint
 // ! Liveness probes basically don't work
 
-func BuildContainer(challengeId, userId, token, namespace, challengeUrl string, testMode bool) *appsv1.Deployment {
+func BuildContainer(challengeId, userId, token, flag, checksum, namespace, challengeUrl string, testMode bool) *appsv1.Deployment {
 	const emptydirDocker = "emptydir-docker"
 	const emptydirFlag = "emptydir-flag"
 	const emptydirCode = "emptydir-code"
@@ -47,7 +47,7 @@ func BuildContainer(challengeId, userId, token, namespace, challengeUrl string,
 				challengeId,
 				token,
 			),
-			`unzip -d "/run/test/solution/" "/run/test/solution.zip"`,
+			verifyAndUnzip("/run/test/solution.zip", "/run/test/solution/", checksum),
 			"docker build /run/test/solution/ -f /run/test/solution/Dockerfile -t test",
 			"docker run -e HTTP_PORT -e SSH_PORT -e DOMAIN -e SSH_SERVICE_INTERNAL_URL -v /run/solution:/run/solution test",
 			"sleep 30",
@@ -70,7 +70,7 @@ func BuildContainer(challengeId, userId, token, namespace, challengeUrl string,
 				challengeId,
 				token,
 			),
-			`unzip -d "/run/challenge/challenge/" "/run/challenge/challenge.zip"`,
+			verifyAndUnzip("/run/challenge/challenge.zip", "/run/challenge/challenge/", checksum),
 			// Needs to be attached. Container will stop once the command finishes
 			`docker compose -f "/run/challenge/challenge/compose.yaml" up`,
 		}
@@ -111,6 +111,11 @@ func BuildContainer(challengeId, userId, token, namespace, challengeUrl string,
 				Name:  "SSH_SERVICE_INTERNAL_URL",
 				Value: sshUrl(challengeUrl),
 			},
+			// Per-instance flag, available to compose.yaml as ${FLAG}
+			{
+				Name:  "FLAG",
+				Value: flag,
+			},
 			// 2376 for TLS; otherwise 2375
 			{
 				Name:  "DOCKER_HOST",
@@ -296,7 +301,7 @@ func BuildContainer(challengeId, userId, token, namespace, challengeUrl string,
 	}
 }
 
-func BuildVm(challengeId, userId, token, namespace, challengeUrl string, testMode bool) *kubevirt.VirtualMachine {
+func BuildVm(challengeId, userId, token, flag, checksum, namespace, challengeUrl string, testMode bool) *kubevirt.VirtualMachine {
 	containerDiskName := "containerdisk"
 	cloudInitDiskName := "cloudinitdisk"
 
@@ -311,7 +316,7 @@ func BuildVm(challengeId, userId, token, namespace, challengeUrl string, testMod
 				token,
 			),
 			`echo "" | tee /dev/ttyS0`,
-			`unzip -d "/run/test/solution/" "/run/test/solution.zip"`,
+			verifyAndUnzip("/run/test/solution.zip", "/run/test/solution/", checksum),
 			"docker build /run/test/solution/ -f /run/test/solution/Dockerfile -t test 2>&1 &> /dev/ttyS0",
 			fmt.Sprintf(
 				`docker run --name test-container -e HTTP_PORT=8080 -e SSH_PORT=8022 -e DOMAIN="%s" -e SSH_SERVICE_INTERNAL_URL="%s" -v /run/solution:/run/solution test`,
@@ -336,11 +341,12 @@ func BuildVm(challengeId, userId, token, namespace, challengeUrl string, testMod
 				challengeId,
 				token,
 			),
-			`unzip -d "/run/challenge/challenge/" "/run/challenge/challenge.zip"`,
+			verifyAndUnzip("/run/challenge/challenge.zip", "/run/challenge/challenge/", checksum),
 			// Can be detached. The VM will be stopped won't the container stops
 			fmt.Sprintf(
-				`HTTP_PORT="8080" SSH_PORT="8022" DOMAIN="%s" docker compose -f /run/challenge/challenge/compose.yaml up -d`,
+				`HTTP_PORT="8080" SSH_PORT="8022" DOMAIN="%s" FLAG="%s" docker compose -f /run/challenge/challenge/compose.yaml up -d`,
 				challengeUrl,
+				flag,
 			),
 			`echo "sleep 3; echo "" | tee /dev/ttyS0; docker compose -f /var/run/challenge/challenge/compose.yaml logs -f 2>&1 | tee /dev/ttyS0" > /run/compose-logs-monitor`,
 			`sh /run/compose-logs-monitor &`,
@@ -495,6 +501,15 @@ ssh_authorized_keys:
 	return userData
 }
 
+// verifyAndUnzip only unzips the archive if it matches the SHA-256 digest recorded on upload
+func verifyAndUnzip(archive, dst, checksum string) string {
+	unzip := fmt.Sprintf(`unzip -d "%s" "%s"`, dst, archive)
+	if checksum == "" {
+		return unzip
+	}
+	return fmt.Sprintf(`echo "%s  %s" | sha256sum -c - && %s`, checksum, archive, unzip)
+}
+
 func buildContainerInit(runCommand []string) string {
 	userData := fmt.Sprintf(`sleep 30
 %s`, strings.Join(runCommand, "\n"))
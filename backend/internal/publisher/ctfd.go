@@ -0,0 +1,380 @@
+package publisher
+
+import (
+	"context"
+	"crypto/sha1"
+	"deployer/internal/ctfdclient"
+	"deployer/internal/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+
+	ctfd "github.com/ctfer-io/go-ctfd/api"
+)
+
+// scoring returns the decay settings of the challenge, allowing extra to be omitted
+func scoring(conf storage.ChallengeCtfd) (function string, initial, decay, minimum int) {
+	if conf.Extra != nil {
+		return conf.Extra.Function, conf.Extra.Initial, conf.Extra.Decay, conf.Extra.Minimum
+	}
+	return "linear", conf.Value, 0, conf.Value
+}
+
+// CtfdPublisher publishes to a CTFd instance through its admin API
+type CtfdPublisher struct {
+	url   string
+	token string
+}
+
+func NewCtfdPublisher(url, token string) (*CtfdPublisher, error) {
+	if url == "" {
+		return nil, errors.New("the ctfd publisher needs a URL")
+	}
+	return &CtfdPublisher{url: url, token: token}, nil
+}
+
+func ctfdRequirements(challenge Challenge) *ctfd.Requirements {
+	prerequisites := challenge.Requirements
+	if prerequisites == nil {
+		prerequisites = []int{}
+	}
+	return &ctfd.Requirements{Prerequisites: prerequisites, Anonymize: &challenge.AnonymizeRequirements}
+}
+
+func (p *CtfdPublisher) Create(ctx context.Context, challenge Challenge) (int, error) {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return 0, err
+	}
+
+	conf := challenge.Config
+	var requirements *ctfd.Requirements
+	if len(challenge.Requirements) > 0 {
+		requirements = ctfdRequirements(challenge)
+	}
+	function, initial, decay, minimum := scoring(conf)
+	ch, err := client.PostChallenges(&ctfd.PostChallengesParams{
+		Name:           conf.Name,
+		Category:       conf.Category,
+		Description:    conf.Description,
+		ConnectionInfo: &conf.ConnectionInfo,
+		Value:          conf.Value,
+		MaxAttempts:    &conf.Attempts,
+		Function:       function,
+		Initial:        &initial,
+		Decay:          &decay,
+		Minimum:        &minimum,
+		Requirements:   requirements,
+		State:          conf.State,
+		Type:           conf.Type,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return ch.ID, syncCtfdLabels(client, ch.ID, conf)
+}
+
+// Update changes the existing CTFd challenge in place, so solves, submissions and unlocked hints are kept
+func (p *CtfdPublisher) Update(ctx context.Context, id int, challenge Challenge) error {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return err
+	}
+
+	conf := challenge.Config
+	current, err := client.GetChallenge(id)
+	if err != nil {
+		return fmt.Errorf("error reading CTFd challenge %d: %v", id, err)
+	}
+	if current.Type != conf.Type {
+		return fmt.Errorf("the type of CTFd challenge %d is '%s' and cannot be changed to '%s'", id, current.Type, conf.Type)
+	}
+
+	function, initial, decay, minimum := scoring(conf)
+	_, err = client.PatchChallenge(id, &ctfd.PatchChallengeParams{
+		Name:           conf.Name,
+		Category:       conf.Category,
+		Description:    conf.Description,
+		ConnectionInfo: &conf.ConnectionInfo,
+		Value:          &conf.Value,
+		MaxAttempts:    &conf.Attempts,
+		Function:       function,
+		Initial:        &initial,
+		Decay:          &decay,
+		Minimum:        &minimum,
+		Requirements:   ctfdRequirements(challenge),
+		// Visibility of published challenges is changed with the hide and unhide endpoints
+		State: current.State,
+	})
+	if err != nil {
+		return err
+	}
+	return syncCtfdLabels(client, id, conf)
+}
+
+func (p *CtfdPublisher) Delete(ctx context.Context, id int) error {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return err
+	}
+	return client.DeleteChallenge(id)
+}
+
+func (p *CtfdPublisher) SetState(ctx context.Context, id int, state string) error {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return err
+	}
+
+	current, err := client.GetChallenge(id)
+	if err != nil {
+		return err
+	}
+	// CTFd replaces these fields on every patch, so the current values are sent back
+	_, err = client.PatchChallenge(id, &ctfd.PatchChallengeParams{
+		Name:        current.Name,
+		Category:    current.Category,
+		Description: current.Description,
+		Function:    current.Function,
+		State:       state,
+	})
+	return err
+}
+
+func (p *CtfdPublisher) SetFlags(ctx context.Context, id int, flags []storage.FlagClass) error {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return err
+	}
+	return syncCtfdFlags(client, id, flags)
+}
+
+func (p *CtfdPublisher) SetFiles(ctx context.Context, id int, files []File) error {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return err
+	}
+	var inputs []*ctfd.InputFile
+	for _, file := range files {
+		inputs = append(inputs, &ctfd.InputFile{Name: file.Name, Content: file.Content})
+	}
+	return syncCtfdFiles(client, id, inputs)
+}
+
+func (p *CtfdPublisher) SetHints(ctx context.Context, id int, hints []storage.HintElement) error {
+	client, err := ctfdclient.New(p.url, p.token)
+	if err != nil {
+		return err
+	}
+	return syncCtfdHints(client, id, hints)
+}
+
+// syncCtfdLabels makes the tags and topics of the CTFd challenge match challenge.yml
+func syncCtfdLabels(client *ctfd.Client, id int, conf storage.ChallengeCtfd) error {
+	err := syncCtfdTags(client, id, conf.Tags)
+	if err != nil {
+		return fmt.Errorf("error updating tags: %v", err)
+	}
+	err = syncCtfdTopics(client, id, conf.Topics)
+	if err != nil {
+		return fmt.Errorf("error updating topics: %v", err)
+	}
+	return nil
+}
+
+func syncCtfdFlags(client *ctfd.Client, id int, flags []storage.FlagClass) error {
+	current, err := client.GetChallengeFlags(id)
+	if err != nil {
+		return err
+	}
+
+	var missing []storage.FlagClass
+	for _, flag := range flags {
+		data := ""
+		if flag.Data != nil {
+			data = *flag.Data
+		}
+		i := slices.IndexFunc(current, func(f *ctfd.Flag) bool {
+			return f.Type == flag.Type && f.Content == flag.Content && f.Data == data
+		})
+		if i >= 0 {
+			current = slices.Delete(current, i, i+1)
+			continue
+		}
+		missing = append(missing, flag)
+	}
+
+	for _, flag := range current {
+		err = client.DeleteFlag(strconv.Itoa(flag.ID))
+		if err != nil {
+			return err
+		}
+	}
+	for _, flag := range missing {
+		data := ""
+		if flag.Data != nil {
+			data = *flag.Data
+		}
+		_, err = client.PostFlags(&ctfd.PostFlagsParams{
+			Challenge: id,
+			Content:   flag.Content,
+			Data:      data,
+			Type:      flag.Type,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCtfdFiles keeps files with the same name and content, and replaces the others
+func syncCtfdFiles(client *ctfd.Client, id int, files []*ctfd.InputFile) error {
+	current, err := client.GetChallengeFiles(id)
+	if err != nil {
+		return err
+	}
+
+	var missing []*ctfd.InputFile
+	for _, file := range files {
+		digest := sha1.Sum(file.Content)
+		sum := hex.EncodeToString(digest[:])
+		i := slices.IndexFunc(current, func(f *ctfd.File) bool {
+			return path.Base(f.Location) == file.Name && f.SHA1sum == sum
+		})
+		if i >= 0 {
+			current = slices.Delete(current, i, i+1)
+			continue
+		}
+		missing = append(missing, file)
+	}
+
+	for _, file := range current {
+		err = client.DeleteFile(strconv.Itoa(file.ID))
+		if err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		_, err = client.PostFiles(&ctfd.PostFilesParams{
+			Files:     missing,
+			Challenge: &id,
+		})
+	}
+	return err
+}
+
+// syncCtfdHints keeps unchanged hints and edits changed hints in place, so players keep the hints they unlocked
+func syncCtfdHints(client *ctfd.Client, id int, hints []storage.HintElement) error {
+	current, err := client.GetChallengeHints(id)
+	if err != nil {
+		return err
+	}
+
+	var missing []storage.HintElement
+	for _, hint := range hints {
+		i := slices.IndexFunc(current, func(h *ctfd.Hint) bool {
+			return h.Content != nil && *h.Content == hint.Content() && h.Cost == hint.Cost()
+		})
+		if i >= 0 {
+			current = slices.Delete(current, i, i+1)
+			continue
+		}
+		missing = append(missing, hint)
+	}
+
+	for i, hint := range missing {
+		if i < len(current) {
+			_, err = client.PatchHint(strconv.Itoa(current[i].ID), &ctfd.PatchHintsParams{
+				ChallengeID:  id,
+				Content:      hint.Content(),
+				Cost:         hint.Cost(),
+				Requirements: ctfd.Requirements{Prerequisites: []int{}},
+			})
+		} else {
+			_, err = client.PostHints(&ctfd.PostHintsParams{
+				ChallengeID:  id,
+				Content:      hint.Content(),
+				Cost:         hint.Cost(),
+				Requirements: ctfd.Requirements{Prerequisites: []int{}},
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := len(missing); i < len(current); i++ {
+		err = client.DeleteHint(strconv.Itoa(current[i].ID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncCtfdTags(client *ctfd.Client, id int, tags []string) error {
+	current, err := client.GetChallengeTags(id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range current {
+		if slices.Contains(tags, tag.Value) {
+			continue
+		}
+		err = client.DeleteTag(strconv.Itoa(tag.ID))
+		if err != nil {
+			return err
+		}
+	}
+	for _, tag := range tags {
+		if slices.ContainsFunc(current, func(t *ctfd.Tag) bool { return t.Value == tag }) {
+			continue
+		}
+		_, err = client.PostTags(&ctfd.PostTagsParams{
+			Challenge: id,
+			Value:     tag,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncCtfdTopics(client *ctfd.Client, id int, topics []string) error {
+	current, err := client.GetChallengeTopics(id)
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range current {
+		if slices.Contains(topics, topic.Value) {
+			continue
+		}
+		err = client.DeleteTopic(&ctfd.DeleteTopicArgs{
+			ID:   strconv.Itoa(topic.ID),
+			Type: "challenge",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, topic := range topics {
+		if slices.ContainsFunc(current, func(t *ctfd.Topic) bool { return t.Value == topic }) {
+			continue
+		}
+		_, err = client.PostTopics(&ctfd.PostTopicsParams{
+			Challenge: id,
+			Type:      "challenge",
+			Value:     topic,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package publisher
+
+import (
+	"context"
+	"deployer/config"
+	"deployer/internal/storage"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+)
+
+const (
+	PublisherCtfd    = "ctfd"
+	PublisherWebhook = "webhook"
+)
+
+// Challenge is everything published for one challenge
+type Challenge struct {
+	Config storage.ChallengeCtfd
+	Flags  []storage.FlagClass
+	// Platform IDs of the challenges that must be solved first
+	Requirements          []int
+	AnonymizeRequirements bool
+	Files                 []File
+}
+
+type File struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content"`
+}
+
+// Publisher puts challenges on the scoreboard platform of a publish target.
+// IDs are assigned by the platform and stored per target.
+type Publisher interface {
+	// Create adds the challenge with its tags and topics, and returns its ID
+	Create(ctx context.Context, challenge Challenge) (int, error)
+	// Update changes the challenge in place, keeping solves and submissions
+	Update(ctx context.Context, id int, challenge Challenge) error
+	Delete(ctx context.Context, id int) error
+	// SetState makes the challenge "visible" or "hidden" to players
+	SetState(ctx context.Context, id int, state string) error
+	// SetFlags, SetFiles and SetHints replace what the challenge has with the given list
+	SetFlags(ctx context.Context, id int, flags []storage.FlagClass) error
+	SetFiles(ctx context.Context, id int, files []File) error
+	SetHints(ctx context.Context, id int, hints []storage.HintElement) error
+}
+
+var ErrUnknownTarget = errors.New("unknown publish target")
+
+var publishers = map[string]Publisher{}
+var mutex sync.Mutex
+
+// GetPublisher returns the publisher of the named target, or ErrUnknownTarget
+func GetPublisher(target string) (Publisher, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if publisher, ok := publishers[target]; ok {
+		return publisher, nil
+	}
+	cfg, ok := config.Values.PublishTargets[target]
+	if !ok {
+		return nil, ErrUnknownTarget
+	}
+	publisher, err := newPublisher(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating publisher of target %s: %v", target, err)
+	}
+	publishers[target] = publisher
+	return publisher, nil
+}
+
+// DefaultTarget is the target whose IDs are stored as the ctfd_id of challenges, used when no target is given
+func DefaultTarget() string {
+	return config.Values.DefaultPublishTarget
+}
+
+// Targets returns the names of all configured targets
+func Targets() []string {
+	targets := make([]string, 0, len(config.Values.PublishTargets))
+	for name := range config.Values.PublishTargets {
+		targets = append(targets, name)
+	}
+	slices.Sort(targets)
+	return targets
+}
+
+func newPublisher(cfg config.PublishTargetConfig) (Publisher, error) {
+	switch cfg.Type {
+	case "", PublisherCtfd:
+		return NewCtfdPublisher(cfg.CtfdUrl, cfg.CtfdApiToken)
+	case PublisherWebhook:
+		return NewWebhookPublisher(cfg.WebhookUrl, cfg.WebhookSecret)
+	default:
+		return nil, errors.New("unknown publisher: " + cfg.Type)
+	}
+}
+
+// Publish creates the challenge, or updates it if it already has an ID, and then sets its flags, files and hints.
+// The id is 0 for challenges that were not published yet.
+func Publish(ctx context.Context, p Publisher, id int, challenge Challenge) (int, error) {
+	var err error
+	if id != 0 {
+		log.Printf("Updating published challenge %d\n", id)
+		err = p.Update(ctx, id, challenge)
+	} else {
+		log.Printf("Adding challenge of type: '%s'\n", challenge.Config.Type)
+		id, err = p.Create(ctx, challenge)
+	}
+	if err != nil {
+		return id, err
+	}
+
+	err = p.SetFlags(ctx, id, challenge.Flags)
+	if err != nil {
+		return id, fmt.Errorf("error updating flags: %v", err)
+	}
+	err = p.SetFiles(ctx, id, challenge.Files)
+	if err != nil {
+		return id, fmt.Errorf("error updating files: %v", err)
+	}
+	err = p.SetHints(ctx, id, challenge.Config.Hints)
+	if err != nil {
+		return id, fmt.Errorf("error updating hints: %v", err)
+	}
+	return id, nil
+}
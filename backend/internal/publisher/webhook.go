@@ -0,0 +1,161 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"deployer/internal/storage"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const signatureHeader = "X-Deployer-Signature"
+
+// WebhookPublisher sends every change as a JSON event to one URL, for platforms without a CTFd compatible API.
+// The receiver answers challenge.create with {"id": <int>}, other events only need a 2xx status.
+type WebhookPublisher struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+type webhookEvent struct {
+	Event string `json:"event"`
+	Id    int    `json:"id,omitempty"`
+	Data  any    `json:"data,omitempty"`
+}
+
+type webhookChallenge struct {
+	Name                  string         `json:"name"`
+	Author                string         `json:"author"`
+	Category              string         `json:"category"`
+	Description           string         `json:"description"`
+	ConnectionInfo        string         `json:"connection_info"`
+	Value                 int            `json:"value"`
+	Type                  string         `json:"type"`
+	Extra                 *storage.Extra `json:"extra"`
+	Attempts              int            `json:"attempts"`
+	State                 string         `json:"state"`
+	Tags                  []string       `json:"tags"`
+	Topics                []string       `json:"topics"`
+	Requirements          []int          `json:"requirements"`
+	AnonymizeRequirements bool           `json:"anonymize_requirements"`
+}
+
+func NewWebhookPublisher(url, secret string) (*WebhookPublisher, error) {
+	if url == "" {
+		return nil, errors.New("the webhook publisher needs a URL")
+	}
+	return &WebhookPublisher{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// send posts the event, signing the body with HMAC-SHA256 if a secret is configured, and decodes the answer into response
+func (p *WebhookPublisher) send(ctx context.Context, event webhookEvent, response any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(p.secret) > 0 {
+		mac := hmac.New(sha256.New, p.secret)
+		mac.Write(body)
+		req.Header.Set(signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook answered %s to %s: %s", resp.Status, event.Event, message)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+func toWebhookChallenge(challenge Challenge) webhookChallenge {
+	conf := challenge.Config
+	return webhookChallenge{
+		Name:                  conf.Name,
+		Author:                conf.Author,
+		Category:              conf.Category,
+		Description:           conf.Description,
+		ConnectionInfo:        conf.ConnectionInfo,
+		Value:                 conf.Value,
+		Type:                  conf.Type,
+		Extra:                 conf.Extra,
+		Attempts:              conf.Attempts,
+		State:                 conf.InitialState(),
+		Tags:                  conf.Tags,
+		Topics:                conf.Topics,
+		Requirements:          challenge.Requirements,
+		AnonymizeRequirements: challenge.AnonymizeRequirements,
+	}
+}
+
+func (p *WebhookPublisher) Create(ctx context.Context, challenge Challenge) (int, error) {
+	var response struct {
+		Id int `json:"id"`
+	}
+	err := p.send(ctx, webhookEvent{Event: "challenge.create", Data: toWebhookChallenge(challenge)}, &response)
+	if err != nil {
+		return 0, err
+	}
+	if response.Id <= 0 {
+		return 0, errors.New("webhook did not answer challenge.create with an id")
+	}
+	return response.Id, nil
+}
+
+func (p *WebhookPublisher) Update(ctx context.Context, id int, challenge Challenge) error {
+	return p.send(ctx, webhookEvent{Event: "challenge.update", Id: id, Data: toWebhookChallenge(challenge)}, nil)
+}
+
+func (p *WebhookPublisher) Delete(ctx context.Context, id int) error {
+	return p.send(ctx, webhookEvent{Event: "challenge.delete", Id: id}, nil)
+}
+
+func (p *WebhookPublisher) SetState(ctx context.Context, id int, state string) error {
+	return p.send(ctx, webhookEvent{Event: "challenge.state", Id: id, Data: map[string]string{"state": state}}, nil)
+}
+
+func (p *WebhookPublisher) SetFlags(ctx context.Context, id int, flags []storage.FlagClass) error {
+	if flags == nil {
+		flags = []storage.FlagClass{}
+	}
+	return p.send(ctx, webhookEvent{Event: "challenge.flags", Id: id, Data: flags}, nil)
+}
+
+// SetFiles sends the file contents base64 encoded
+func (p *WebhookPublisher) SetFiles(ctx context.Context, id int, files []File) error {
+	if files == nil {
+		files = []File{}
+	}
+	return p.send(ctx, webhookEvent{Event: "challenge.files", Id: id, Data: files}, nil)
+}
+
+func (p *WebhookPublisher) SetHints(ctx context.Context, id int, hints []storage.HintElement) error {
+	result := []storage.HintClass{}
+	for _, hint := range hints {
+		result = append(result, storage.HintClass{Content: hint.Content(), Cost: hint.Cost()})
+	}
+	return p.send(ctx, webhookEvent{Event: "challenge.hints", Id: id, Data: result}, nil)
+}
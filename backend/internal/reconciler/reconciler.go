@@ -0,0 +1,230 @@
+package reconciler
+
+import (
+	"context"
+	"deployer/config"
+	"deployer/internal/ctfdclient"
+	"deployer/internal/infrastructure"
+	"deployer/internal/publisher"
+	"deployer/internal/storage"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	ctfd "github.com/ctfer-io/go-ctfd/api"
+)
+
+const (
+	// The challenge is published with a CTFd ID that no longer exists in CTFd
+	DriftMissingInCtfd = "missing_in_ctfd"
+	// A CTFd challenge that no challenge in the database points to
+	DriftOrphanedInCtfd = "orphaned_in_ctfd"
+	// Name or category differ, publishing the challenge again fixes it
+	DriftMetadata = "metadata"
+	// The challenge was hidden or unhidden in CTFd directly
+	DriftState = "state"
+)
+
+type Drift struct {
+	Kind string `json:"kind"`
+	// Empty for challenges that only exist in CTFd
+	ChallengeId string `json:"challenge_id"`
+	CtfdId      int    `json:"ctfd_id"`
+	Detail      string `json:"detail"`
+	Repaired    bool   `json:"repaired"`
+}
+
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Drift     []Drift   `json:"drift"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	lastReport Report
+	mutex      sync.Mutex
+)
+
+// Start periodically compares the challenges published to the default target with its CTFd, repairing the drift if configured
+func Start() {
+	interval := config.Values.Reconcile.IntervalMinutes
+	if interval <= 0 || !enabled() {
+		log.Println("CTFd reconciliation is disabled")
+		return
+	}
+
+	for range time.Tick(time.Minute * time.Duration(interval)) {
+		log.Println("Comparing published challenges with CTFd")
+		report := Run(config.Values.Reconcile.Repair)
+		if report.Error != "" {
+			log.Println("CTFd reconciliation failed: " + report.Error)
+		} else if len(report.Drift) > 0 {
+			log.Printf("Found %d differences between the database and CTFd", len(report.Drift))
+		}
+	}
+}
+
+// enabled reports whether the default target is a CTFd, the only platform that can be compared
+func enabled() bool {
+	target := config.Values.PublishTargets[publisher.DefaultTarget()]
+	return target.Type == "" || target.Type == publisher.PublisherCtfd
+}
+
+func newClient() (*ctfd.Client, error) {
+	target := config.Values.PublishTargets[publisher.DefaultTarget()]
+	return ctfdclient.New(target.CtfdUrl, target.CtfdApiToken)
+}
+
+// LastReport returns the result of the latest run, which has a zero CheckedAt if nothing ran yet
+func LastReport() Report {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return lastReport
+}
+
+// Run compares the database with CTFd now and stores the result as the latest report
+func Run(repair bool) Report {
+	report := Report{CheckedAt: time.Now(), Drift: []Drift{}}
+	if !enabled() {
+		report.Error = "the default publish target is not a CTFd"
+		return report
+	}
+	drift, err := check()
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Drift = drift
+	}
+
+	if repair {
+		for i := range report.Drift {
+			err = repairDrift(&report.Drift[i])
+			if err != nil {
+				log.Printf("Could not repair %s drift of %s: %v", report.Drift[i].Kind, report.Drift[i].ChallengeId, err)
+			}
+		}
+	}
+
+	mutex.Lock()
+	lastReport = report
+	mutex.Unlock()
+	return report
+}
+
+func check() ([]Drift, error) {
+	result := []Drift{}
+
+	client, err := newClient()
+	if err != nil {
+		return result, err
+	}
+	view := "admin"
+	ctfdChallenges, err := client.GetChallenges(&ctfd.GetChallengesParams{View: &view})
+	if err != nil {
+		return result, err
+	}
+	remaining := map[int]*ctfd.Challenge{}
+	for _, ch := range ctfdChallenges {
+		remaining[ch.ID] = ch
+	}
+
+	challenges, _, err := storage.ListChallenges(storage.ChallengeFilter{})
+	if err != nil {
+		return result, err
+	}
+
+	for _, challenge := range challenges {
+		if !challenge.CtfdId.Valid {
+			continue
+		}
+		id := int(challenge.CtfdId.Int64)
+		if _, ok := remaining[id]; !ok {
+			result = append(result, Drift{
+				Kind:        DriftMissingInCtfd,
+				ChallengeId: challenge.Id,
+				CtfdId:      id,
+				Detail:      fmt.Sprintf("CTFd challenge %d does not exist", id),
+			})
+			continue
+		}
+		delete(remaining, id)
+
+		// The list leaves out the state, so read the challenge itself
+		current, err := client.GetChallenge(id)
+		if err != nil {
+			return result, err
+		}
+		if current.Name != challenge.Name || current.Category != challenge.Category {
+			result = append(result, Drift{
+				Kind:        DriftMetadata,
+				ChallengeId: challenge.Id,
+				CtfdId:      id,
+				Detail:      fmt.Sprintf("CTFd has '%s' in '%s', challenge.yml has '%s' in '%s'", current.Name, current.Category, challenge.Name, challenge.Category),
+			})
+		}
+		if current.State != challenge.State {
+			result = append(result, Drift{
+				Kind:        DriftState,
+				ChallengeId: challenge.Id,
+				CtfdId:      id,
+				Detail:      fmt.Sprintf("CTFd state is '%s', expected '%s'", current.State, challenge.State),
+			})
+		}
+	}
+
+	for _, ch := range ctfdChallenges {
+		if _, ok := remaining[ch.ID]; !ok {
+			continue
+		}
+		result = append(result, Drift{
+			Kind:   DriftOrphanedInCtfd,
+			CtfdId: ch.ID,
+			Detail: fmt.Sprintf("CTFd challenge '%s' is not managed by the deployer", ch.Name),
+		})
+	}
+	return result, nil
+}
+
+// repairDrift fixes the database side of the drift. CTFd is never changed, metadata drift needs the challenge to be published again.
+func repairDrift(drift *Drift) error {
+	switch drift.Kind {
+	case DriftMissingInCtfd:
+		err := storage.UnpublishChallenge(drift.ChallengeId)
+		if err != nil {
+			return err
+		}
+		err = storage.DeletePublication(drift.ChallengeId, publisher.DefaultTarget())
+		if err != nil {
+			return err
+		}
+		// Players can no longer reach the challenge, like after unpublishing it
+		stopped, err := infrastructure.StopPlayerInstances(context.TODO(), drift.ChallengeId)
+		if err != nil {
+			return err
+		}
+		log.Printf("Stopped %d player instances of %s", stopped, drift.ChallengeId)
+	case DriftState:
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		current, err := client.GetChallenge(drift.CtfdId)
+		if err != nil {
+			return err
+		}
+		err = storage.SetChallengeState(drift.ChallengeId, current.State)
+		if err != nil {
+			return err
+		}
+		err = storage.SetPublicationState(drift.ChallengeId, publisher.DefaultTarget(), current.State)
+		if err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	drift.Repaired = true
+	log.Printf("Repaired %s drift of %s", drift.Kind, drift.ChallengeId)
+	return nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"deployer/internal/auth"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ApiKey is a key for scripts and CI jobs. Only the hash of the key is stored.
+type ApiKey struct {
+	Id     string `json:"id"`
+	UserId string `json:"user_id"`
+	Name   string `json:"name"`
+	// First characters of the key, to recognize it
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, expires_at, revoked_at, last_used_at, created_at"
+
+// lastUsedInterval limits how often using a key is written to the database
+const lastUsedInterval = "1 minute"
+
+func scanApiKey(row rowScanner) (ApiKey, error) {
+	var result ApiKey
+	err := row.Scan(&result.Id, &result.UserId, &result.Name, &result.Prefix, pq.Array(&result.Scopes),
+		&result.ExpiresAt, &result.RevokedAt, &result.LastUsedAt, &result.CreatedAt)
+	return result, err
+}
+
+func CreateApiKey(userId, name, prefix, hash string, scopes []string, expiresAt *time.Time) (ApiKey, error) {
+	return scanApiKey(Db.QueryRow("INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+apiKeyColumns,
+		userId, name, prefix, hash, pq.Array(scopes), expiresAt))
+}
+
+func GetApiKey(keyId string) (ApiKey, error) {
+	return scanApiKey(Db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id=$1;", keyId))
+}
+
+// ListApiKeys returns the keys of the user, or of every user if userId is empty
+func ListApiKeys(userId string) ([]ApiKey, error) {
+	var result []ApiKey
+
+	rows, err := Db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE $1 = '' OR user_id::text = $1 ORDER BY created_at;", userId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, key)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func RevokeApiKey(keyId string) error {
+	_, err := Db.Exec("UPDATE api_keys SET revoked_at=CURRENT_TIMESTAMP WHERE id=$1 AND revoked_at IS NULL", keyId)
+	return err
+}
+
+// FindApiKey returns the usable key with the hash and the current role of its owner, and records that it was used
+func FindApiKey(hash string) (auth.ApiKey, error) {
+	var result auth.ApiKey
+	err := Db.QueryRow("SELECT k.id, k.user_id, COALESCE(u.role, ''), k.scopes FROM api_keys k JOIN users u ON u.id = k.user_id "+
+		"WHERE k.key_hash=$1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP) AND NOT u.disabled;", hash).
+		Scan(&result.Id, &result.UserId, &result.Role, pq.Array(&result.Scopes))
+	if err != nil {
+		return result, err
+	}
+
+	_, err = Db.Exec("UPDATE api_keys SET last_used_at=CURRENT_TIMESTAMP WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '"+lastUsedInterval+"')", result.Id)
+	return result, err
+}
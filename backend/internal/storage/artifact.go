@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"database/sql"
+	"slices"
+)
+
+// Artifact is an uploaded file of a challenge revision, stored by its SHA-256 digest
+type Artifact struct {
+	ChallengeId string `json:"-"`
+	Revision    int    `json:"-"`
+	Filename    string `json:"filename"`
+	Sha256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+}
+
+func CreateArtifact(artifact Artifact) error {
+	return createArtifact(Db, artifact)
+}
+
+func createArtifact(db execer, artifact Artifact) error {
+	_, err := db.Exec("INSERT INTO artifacts (challenge_id, revision, filename, sha256, size) VALUES ($1, $2, $3, $4, $5)",
+		artifact.ChallengeId, artifact.Revision, artifact.Filename, artifact.Sha256, artifact.Size)
+	return err
+}
+
+func GetArtifact(challengeId string, revision int, filename string) (Artifact, error) {
+	var result Artifact
+
+	err := Db.QueryRow("SELECT challenge_id, revision, filename, sha256, size FROM artifacts WHERE challenge_id=$1 AND revision=$2 AND filename=$3;", challengeId, revision, filename).
+		Scan(&result.ChallengeId, &result.Revision, &result.Filename, &result.Sha256, &result.Size)
+	return result, err
+}
+
+func ListArtifacts(challengeId string) ([]Artifact, error) {
+	var result []Artifact
+
+	rows, err := Db.Query("SELECT challenge_id, revision, filename, sha256, size FROM artifacts WHERE challenge_id=$1 ORDER BY revision, filename;", challengeId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var artifact Artifact
+		err := rows.Scan(&artifact.ChallengeId, &artifact.Revision, &artifact.Filename, &artifact.Sha256, &artifact.Size)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, artifact)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// RemoveUnreferencedBlob calls remove if no artifact points to the digest. The digest is locked meanwhile,
+// so no revision with the blob can be stored between checking and removing it.
+func RemoveUnreferencedBlob(sha256 string, remove func() error) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockBlobs(tx, []string{sha256})
+	if err != nil {
+		return err
+	}
+	var referenced bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM artifacts WHERE sha256=$1);", sha256).Scan(&referenced)
+	if err != nil {
+		return err
+	}
+	if !referenced {
+		err = remove()
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// lockBlobs holds a lock on each digest until the transaction ends. Digests are locked in order, so transactions do not deadlock.
+func lockBlobs(tx *sql.Tx, digests []string) error {
+	digests = slices.Clone(digests)
+	slices.Sort(digests)
+	digests = slices.Compact(digests)
+	for _, digest := range digests {
+		_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended('blob:' || $1, 0))", digest)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRevisionsWithoutArtifacts returns revisions uploaded before artifacts were content addressed
+func ListRevisionsWithoutArtifacts() ([]Revision, error) {
+	return queryRevisions(Db, "SELECT "+revisionColumns+" FROM challenge_revisions r "+
+		"WHERE NOT EXISTS (SELECT 1 FROM artifacts a WHERE a.challenge_id = r.challenge_id AND a.revision = r.number);")
+}
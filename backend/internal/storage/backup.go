@@ -0,0 +1,428 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BackupUser is a row of the users table as kept in a backup, with the recovery codes of its TOTP
+type BackupUser struct {
+	Id            string               `json:"id"`
+	Username      string               `json:"username"`
+	PasswordHash  string               `json:"password_hash"`
+	Role          string               `json:"role"`
+	Disabled      bool                 `json:"disabled"`
+	CreatedAt     time.Time            `json:"created_at"`
+	TotpSecret    *string              `json:"totp_secret"`
+	TotpEnabled   bool                 `json:"totp_enabled"`
+	TotpLastStep  int64                `json:"totp_last_step"`
+	RecoveryCodes []BackupRecoveryCode `json:"recovery_codes"`
+}
+
+type BackupRecoveryCode struct {
+	CodeHash string     `json:"code_hash"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// BackupApiKey is a row of the api_keys table as kept in a backup. Only the hash of the key is kept, like in the database.
+type BackupApiKey struct {
+	ApiKey
+	KeyHash string `json:"key_hash"`
+}
+
+// BackupChallenge is a challenge with every row that belongs to it. Instances and submissions are not kept.
+type BackupChallenge struct {
+	Challenge
+	CreatedAt    time.Time        `json:"created_at"`
+	Flags        []FlagClass      `json:"flags"`
+	Revisions    []Revision       `json:"revisions"`
+	Artifacts    []BackupArtifact `json:"artifacts"`
+	Publications []Publication    `json:"publications"`
+}
+
+type BackupArtifact struct {
+	Revision  int       `json:"revision"`
+	Filename  string    `json:"filename"`
+	Sha256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreResult tells how the IDs in the backup were restored. IDs that are not mapped were preserved.
+type RestoreResult struct {
+	UsersCreated int `json:"users_created"`
+	// Users whose username already existed, from the ID in the backup to the existing ID
+	UsersMapped       map[string]string `json:"users_mapped"`
+	ChallengesCreated int               `json:"challenges_created"`
+	// Challenges whose ID already exists, e.g. because the backup was restored before. They are kept as they are.
+	ChallengesSkipped []string `json:"challenges_skipped"`
+	// Keys of users that already existed are not restored
+	ApiKeysCreated int `json:"api_keys_created"`
+}
+
+// ListBackup reads the users, challenges and API keys in one read-only transaction, so they are a consistent snapshot
+func ListBackup() ([]BackupUser, []BackupChallenge, []BackupApiKey, error) {
+	tx, err := Db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	users, err := listBackupUsers(tx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	challenges, err := listBackupChallenges(tx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	apiKeys, err := listBackupApiKeys(tx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return users, challenges, apiKeys, tx.Commit()
+}
+
+func listBackupUsers(db querier) ([]BackupUser, error) {
+	var result []BackupUser
+
+	rows, err := db.Query("SELECT id, username, password_hash, COALESCE(role, ''), disabled, created_at, totp_secret, totp_enabled, totp_last_step FROM users ORDER BY created_at;")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user BackupUser
+		err := rows.Scan(&user.Id, &user.Username, &user.PasswordHash, &user.Role, &user.Disabled, &user.CreatedAt,
+			&user.TotpSecret, &user.TotpEnabled, &user.TotpLastStep)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, user)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	for i := range result {
+		result[i].RecoveryCodes, err = listBackupRecoveryCodes(db, result[i].Id)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func listBackupRecoveryCodes(db querier, userId string) ([]BackupRecoveryCode, error) {
+	var result []BackupRecoveryCode
+
+	rows, err := db.Query("SELECT code_hash, used_at FROM recovery_codes WHERE user_id=$1 ORDER BY code_hash;", userId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code BackupRecoveryCode
+		err := rows.Scan(&code.CodeHash, &code.UsedAt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, code)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func listBackupApiKeys(db querier) ([]BackupApiKey, error) {
+	var result []BackupApiKey
+
+	rows, err := db.Query("SELECT " + apiKeyColumns + ", key_hash FROM api_keys ORDER BY created_at;")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key BackupApiKey
+		err := rows.Scan(&key.Id, &key.UserId, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+			&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.CreatedAt, &key.KeyHash)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, key)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func listBackupChallenges(db querier) ([]BackupChallenge, error) {
+	var result []BackupChallenge
+
+	rows, err := db.Query("SELECT " + challengeColumns + ", created_at FROM challenges ORDER BY created_at;")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var challenge BackupChallenge
+		err := rows.Scan(&challenge.Id, &challenge.UserId, &challenge.Published, &challenge.CtfdId, &challenge.Verified, &challenge.Revision,
+			&challenge.Name, &challenge.Category, &challenge.Author, &challenge.Value, pq.Array(&challenge.Tags), &challenge.State, &challenge.CreatedAt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, challenge)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	for i := range result {
+		challenge := &result[i]
+		challenge.Flags, err = getChallengeFlags(db, challenge.Id)
+		if err != nil {
+			return result, err
+		}
+		challenge.Revisions, err = listRevisions(db, challenge.Id)
+		if err != nil {
+			return result, err
+		}
+		challenge.Artifacts, err = listBackupArtifacts(db, challenge.Id)
+		if err != nil {
+			return result, err
+		}
+		challenge.Publications, err = listPublications(db, challenge.Id)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func listBackupArtifacts(db querier, challengeId string) ([]BackupArtifact, error) {
+	var result []BackupArtifact
+
+	rows, err := db.Query("SELECT revision, filename, sha256, size, created_at FROM artifacts WHERE challenge_id=$1 ORDER BY revision, filename;", challengeId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var artifact BackupArtifact
+		err := rows.Scan(&artifact.Revision, &artifact.Filename, &artifact.Sha256, &artifact.Size, &artifact.CreatedAt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, artifact)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// RestoreBackup inserts the users, challenges and API keys of a backup in one transaction.
+// Users that exist with the same username are kept and their challenges are given to the existing user, their API keys are skipped.
+// Challenges whose ID exists are skipped, so restoring a backup twice does not duplicate them.
+// Other IDs are preserved unless they are already taken, then a new ID is generated.
+func RestoreBackup(users []BackupUser, challenges []BackupChallenge, apiKeys []BackupApiKey) (RestoreResult, error) {
+	result := RestoreResult{UsersMapped: map[string]string{}, ChallengesSkipped: []string{}}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// Removing unreferenced blobs waits until the artifacts pointing to the restored blobs are committed
+	var digests []string
+	for _, challenge := range challenges {
+		for _, artifact := range challenge.Artifacts {
+			digests = append(digests, artifact.Sha256)
+		}
+	}
+	err = lockBlobs(tx, digests)
+	if err != nil {
+		return result, err
+	}
+
+	kept := map[string]bool{}
+	for _, user := range users {
+		var existingId string
+		err := tx.QueryRow("SELECT id FROM users WHERE username=$1;", user.Username).Scan(&existingId)
+		if err == nil {
+			kept[user.Id] = true
+			if existingId != user.Id {
+				result.UsersMapped[user.Id] = existingId
+			}
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return result, err
+		}
+
+		id, err := insertWithId(tx, "users", user.Id,
+			"INSERT INTO users (id, username, password_hash, role, disabled, created_at, totp_secret, totp_enabled, totp_last_step) "+
+				"VALUES (COALESCE($1, uuid_generate_v4()), $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9) RETURNING id",
+			user.Username, user.PasswordHash, user.Role, user.Disabled, user.CreatedAt, user.TotpSecret, user.TotpEnabled, user.TotpLastStep)
+		if err != nil {
+			return result, err
+		}
+		for _, code := range user.RecoveryCodes {
+			_, err = tx.Exec("INSERT INTO recovery_codes (user_id, code_hash, used_at) VALUES ($1, $2, $3)", id, code.CodeHash, code.UsedAt)
+			if err != nil {
+				return result, err
+			}
+		}
+		if id != user.Id {
+			result.UsersMapped[user.Id] = id
+		}
+		result.UsersCreated++
+	}
+
+	mapUser := func(userId string) string {
+		if id, ok := result.UsersMapped[userId]; ok {
+			return id
+		}
+		return userId
+	}
+
+	for _, challenge := range challenges {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM challenges WHERE id=$1);", challenge.Id).Scan(&exists)
+		if err != nil {
+			return result, err
+		}
+		if exists {
+			result.ChallengesSkipped = append(result.ChallengesSkipped, challenge.Id)
+			continue
+		}
+
+		if challenge.Tags == nil {
+			challenge.Tags = []string{}
+		}
+		id := challenge.Id
+		_, err = tx.Exec("INSERT INTO challenges (id, user_id, published, ctfd_id, verified, revision, name, category, author, value, tags, state, created_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
+			id, mapUser(challenge.UserId), challenge.Published, challenge.CtfdId, challenge.Verified, challenge.Revision,
+			challenge.Name, challenge.Category, challenge.Author, challenge.Value, pq.Array(challenge.Tags), challenge.State, challenge.CreatedAt)
+		if err != nil {
+			return result, err
+		}
+		result.ChallengesCreated++
+
+		for i, flag := range challenge.Flags {
+			_, err = tx.Exec("INSERT INTO flags (challenge_id, position, type, content, data) VALUES ($1, $2, $3, $4, $5)", id, i, flag.Type, flag.Content, flag.Data)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		for _, revision := range challenge.Revisions {
+			leaks, err := nullJSON(revision.LeakScanPassed, revision.Leaks)
+			if err != nil {
+				return result, err
+			}
+			violations, err := nullJSON(revision.PolicyCheckPassed, revision.PolicyViolations)
+			if err != nil {
+				return result, err
+			}
+			overrideBy := revision.PolicyOverrideBy
+			if overrideBy != nil {
+				userId := mapUser(*overrideBy)
+				overrideBy = &userId
+			}
+			_, err = tx.Exec("INSERT INTO challenge_revisions (challenge_id, number, user_id, created_at, leak_scan_passed, leaks, policy_check_passed, policy_violations, policy_override_by) "+
+				"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+				id, revision.Number, mapUser(revision.UserId), revision.CreatedAt, revision.LeakScanPassed, leaks, revision.PolicyCheckPassed, violations, overrideBy)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		for _, artifact := range challenge.Artifacts {
+			_, err = tx.Exec("INSERT INTO artifacts (challenge_id, revision, filename, sha256, size, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+				id, artifact.Revision, artifact.Filename, artifact.Sha256, artifact.Size, artifact.CreatedAt)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		for _, publication := range challenge.Publications {
+			_, err = tx.Exec("INSERT INTO challenge_publications (challenge_id, target, ctfd_id, revision, state, published_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING",
+				id, publication.Target, publication.CtfdId, publication.Revision, publication.State, publication.PublishedAt)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	for _, key := range apiKeys {
+		if kept[key.UserId] {
+			continue
+		}
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM api_keys WHERE key_hash=$1);", key.KeyHash).Scan(&exists)
+		if err != nil {
+			return result, err
+		}
+		if exists {
+			continue
+		}
+		if key.Scopes == nil {
+			key.Scopes = []string{}
+		}
+		_, err = insertWithId(tx, "api_keys", key.Id,
+			"INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, revoked_at, last_used_at, created_at) "+
+				"VALUES (COALESCE($1, uuid_generate_v4()), $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id",
+			mapUser(key.UserId), key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt, key.RevokedAt, key.LastUsedAt, key.CreatedAt)
+		if err != nil {
+			return result, err
+		}
+		result.ApiKeysCreated++
+	}
+
+	return result, tx.Commit()
+}
+
+// insertWithId runs the insert with the ID as its first argument, or with NULL to generate a new ID if the table already has a row with it
+func insertWithId(tx *sql.Tx, table, id, query string, args ...any) (string, error) {
+	var taken bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id=$1);", id).Scan(&taken)
+	if err != nil {
+		return "", err
+	}
+
+	var preserved *string
+	if !taken {
+		preserved = &id
+	}
+	var result string
+	err = tx.QueryRow(query, append([]any{preserved}, args...)...).Scan(&result)
+	return result, err
+}
+
+// nullJSON encodes the findings of a check, or NULL if the check has not run
+func nullJSON(passed *bool, findings any) (*string, error) {
+	if passed == nil {
+		return nil, nil
+	}
+	content, err := json.Marshal(findings)
+	if err != nil {
+		return nil, err
+	}
+	result := string(content)
+	return &result, nil
+}
@@ -3,9 +3,10 @@ package storage
 import (
 	"database/sql"
 	"fmt"
-	"os"
 	"strconv"
+	"strings"
 
+	"github.com/lib/pq"
 	"gopkg.in/yaml.v2"
 )
 
@@ -15,6 +16,49 @@ type Challenge struct {
 	Published bool          `json:"published"`
 	CtfdId    sql.NullInt64 `json:"ctfd_id"`
 	Verified  bool          `json:"verified"`
+	Revision  int           `json:"revision"`
+	// Copied from challenge.yml of the active revision. State follows CTFd once the challenge is published.
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Author   string   `json:"author"`
+	Value    int      `json:"value"`
+	Tags     []string `json:"tags"`
+	State    string   `json:"state"`
+}
+
+// ChallengeFilter narrows down ListChallenges. Zero values do not filter.
+type ChallengeFilter struct {
+	UserId    string
+	Name      string
+	Category  string
+	Tag       string
+	Published *bool
+	Verified  *bool
+	// Matched case-insensitively against name, category, author and tags
+	Search string
+	// One of the challengeSortColumns keys
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+const challengeColumns = "id, user_id, published, ctfd_id, verified, revision, name, category, author, value, tags, state"
+
+var challengeSortColumns = map[string]string{
+	"name":     "name",
+	"category": "category",
+	"author":   "author",
+	"value":    "value",
+	"state":    "state",
+	"created":  "created_at",
+}
+
+func scanChallenge(row rowScanner) (Challenge, error) {
+	var result Challenge
+	err := row.Scan(&result.Id, &result.UserId, &result.Published, &result.CtfdId, &result.Verified, &result.Revision,
+		&result.Name, &result.Category, &result.Author, &result.Value, pq.Array(&result.Tags), &result.State)
+	return result, err
 }
 
 type ChallengeCtfd struct {
@@ -31,12 +75,12 @@ type ChallengeCtfd struct {
 	ConnectionInfo string        `json:"connection_info"`
 	Healthcheck    string        `json:"healthcheck"`
 	Attempts       int           `json:"attempts"`
-	Flags          []string      `json:"flags"`
+	Flags          []FlagElement `json:"flags"`
 	Topics         []string      `json:"topics"`
 	Tags           []string      `json:"tags"`
 	Files          []string      `json:"files"`
 	Hints          []HintElement `json:"hints"`
-	Requirements   []string      `json:"requirements"`
+	Requirements   Requirements  `json:"requirements"`
 	State          string        `json:"state"`
 	Version        string        `json:"version"`
 }
@@ -54,33 +98,110 @@ type FlagClass struct {
 	Data    *string `json:"data,omitempty"`
 }
 
+// Flags in challenge.yml are either a plain string or a mapping with type, content and data
+type FlagElement struct {
+	FlagClass *FlagClass
+	String    *string
+}
+
+func (f *FlagElement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		f.String = &value
+		return nil
+	}
+
+	var class FlagClass
+	if err := unmarshal(&class); err != nil {
+		return err
+	}
+	f.FlagClass = &class
+	return nil
+}
+
 type HintClass struct {
 	Content string `json:"content"`
 	Cost    int    `json:"cost"`
 }
 
+// Hints in challenge.yml are either a plain string or a mapping with content and cost
 type HintElement struct {
 	HintClass *HintClass
 	String    *string
 }
 
-func GetChallenge(challengeId string) (Challenge, error) {
-	var result Challenge
+func (h *HintElement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		h.String = &value
+		return nil
+	}
 
-	err := Db.QueryRow("SELECT id, user_id, published, ctfd_id, verified FROM challenges WHERE id=$1;", challengeId).Scan(&result.Id, &result.UserId, &result.Published, &result.CtfdId, &result.Verified)
-	return result, err
+	var class HintClass
+	if err := unmarshal(&class); err != nil {
+		return err
+	}
+	h.HintClass = &class
+	return nil
 }
 
-func GetChallengeByCtfdId(ctfdId int) (Challenge, error) {
-	var result Challenge
+func (h HintElement) Content() string {
+	if h.HintClass != nil {
+		return h.HintClass.Content
+	}
+	if h.String != nil {
+		return *h.String
+	}
+	return ""
+}
 
-	err := Db.QueryRow("SELECT id, user_id, published, ctfd_id, verified FROM challenges WHERE ctfd_id=$1;", ctfdId).Scan(&result.Id, &result.UserId, &result.Published, &result.CtfdId, &result.Verified)
-	return result, err
+func (h HintElement) Cost() int {
+	if h.HintClass != nil {
+		return h.HintClass.Cost
+	}
+	return 0
 }
 
-func UpdateChallengeFlag(challengeId string, flag string) error {
-	_, err := Db.Exec("UPDATE challenges SET flag=$1 WHERE id=$2", flag, challengeId)
-	return err
+// Requirements in challenge.yml are either a list of prerequisites or a mapping with prerequisites and anonymize.
+// Prerequisites are names of other challenges or their CTFd IDs.
+type Requirements struct {
+	Prerequisites []string `json:"prerequisites"`
+	Anonymize     bool     `json:"anonymize"`
+}
+
+func (r *Requirements) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []interface{}
+	if err := unmarshal(&list); err == nil {
+		r.Prerequisites = requirementNames(list)
+		return nil
+	}
+
+	var class struct {
+		Prerequisites []interface{} `yaml:"prerequisites"`
+		Anonymize     bool          `yaml:"anonymize"`
+	}
+	if err := unmarshal(&class); err != nil {
+		return err
+	}
+	r.Prerequisites = requirementNames(class.Prerequisites)
+	r.Anonymize = class.Anonymize
+	return nil
+}
+
+func requirementNames(list []interface{}) []string {
+	var result []string
+	for _, item := range list {
+		result = append(result, fmt.Sprint(item))
+	}
+	return result
+}
+
+func GetChallenge(challengeId string) (Challenge, error) {
+	return scanChallenge(Db.QueryRow("SELECT "+challengeColumns+" FROM challenges WHERE id=$1;", challengeId))
+}
+
+func GetChallengeByCtfdId(ctfdId int) (Challenge, error) {
+	return scanChallenge(Db.QueryRow("SELECT "+challengeColumns+" FROM challenges WHERE ctfd_id=$1;", ctfdId))
 }
 
 func GetChallengeWrapper(challengeId string) (Challenge, error) {
@@ -100,15 +221,6 @@ func GetChallengeWrapper(challengeId string) (Challenge, error) {
 	return challenge, nil
 }
 
-func GetChallengeFlag(challengeId string) (string, error) {
-	var expectedFlag string
-	err := Db.QueryRow("SELECT flag FROM challenges WHERE id=$1;", challengeId).Scan(&expectedFlag)
-	if err != nil {
-		return "", err
-	}
-	return expectedFlag, err
-}
-
 func MarkChallengeVerified(challengeId string) error {
 	_, err := Db.Exec("UPDATE challenges SET verified=$1 WHERE id=$2", true, challengeId)
 	return err
@@ -119,14 +231,9 @@ func ResetChallengeVerified(challengeId string) error {
 	return err
 }
 
-func ParseChallengeYAML(filePath string) (ChallengeCtfd, error) {
-	fileContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return ChallengeCtfd{}, fmt.Errorf("error reading file: %v", err)
-	}
-
+func ParseChallengeYAML(fileContent []byte) (ChallengeCtfd, error) {
 	var config ChallengeCtfd
-	err = yaml.Unmarshal(fileContent, &config)
+	err := yaml.Unmarshal(fileContent, &config)
 	if err != nil {
 		return ChallengeCtfd{}, fmt.Errorf("error parsing YAML: %v", err)
 	}
@@ -134,40 +241,124 @@ func ParseChallengeYAML(filePath string) (ChallengeCtfd, error) {
 	return config, nil
 }
 
-// ! Only support challenges with a single flag
-func UpdateChallengeFlagGivenChallengeFile(filePath string, challengeId string) error {
-	config, err := ParseChallengeYAML(filePath)
-	if err != nil {
-		return err
+// InitialState is the state a challenge is first published with, "visible" unless challenge.yml says otherwise
+func (c ChallengeCtfd) InitialState() string {
+	if c.State == "" {
+		return "visible"
 	}
-	err = UpdateChallengeFlag(challengeId, config.Flags[0])
-	return err
+	return c.State
+}
+
+func (c ChallengeCtfd) FlagClasses() ([]FlagClass, error) {
+	if len(c.Flags) == 0 {
+		return nil, fmt.Errorf("challenge.yml does not define any flags")
+	}
+
+	var result []FlagClass
+	for _, element := range c.Flags {
+		flag, err := element.Normalize()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, flag)
+	}
+	return result, nil
 }
 
-func ListChallenges(isAdmin bool, userId string) ([]Challenge, error) {
+// ListChallenges returns one page of the challenges matching the filter, and how many match in total
+func ListChallenges(filter ChallengeFilter) ([]Challenge, int, error) {
 	var result []Challenge
-	var rows *sql.Rows
-	var err error
+	var conditions []string
+	var args []any
 
-	if isAdmin {
-		rows, err = Db.Query(
-			"SELECT id, user_id, published, ctfd_id, verified FROM challenges;",
-		)
-	} else {
-		rows, err = Db.Query(
-			"SELECT id, user_id, published, ctfd_id, verified FROM challenges WHERE user_id = $1;",
-			userId,
-		)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.UserId != "" {
+		where("user_id = $?", filter.UserId)
+	}
+	if filter.Name != "" {
+		where("name = $?", filter.Name)
+	}
+	if filter.Category != "" {
+		where("category = $?", filter.Category)
+	}
+	if filter.Tag != "" {
+		where("$? = ANY(tags)", filter.Tag)
+	}
+	if filter.Published != nil {
+		where("published = $?", *filter.Published)
+	}
+	if filter.Verified != nil {
+		where("verified = $?", *filter.Verified)
+	}
+	if filter.Search != "" {
+		where("(name ILIKE $? OR category ILIKE $? OR author ILIKE $? OR array_to_string(tags, ' ') ILIKE $?)", "%"+escapeLike(filter.Search)+"%")
+	}
+
+	query := " FROM challenges"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err := Db.QueryRow("SELECT COUNT(*)"+query+";", args...).Scan(&total)
+	if err != nil {
+		return result, 0, err
+	}
+
+	column, ok := challengeSortColumns[filter.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+	query += " ORDER BY " + column + " " + direction + ", id"
+	if filter.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += " OFFSET " + strconv.Itoa(filter.Offset)
+	}
+
+	rows, err := Db.Query("SELECT "+challengeColumns+query+";", args...)
+	if err != nil {
+		return result, total, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		challenge, err := scanChallenge(rows)
+		if err != nil {
+			return result, total, err
+		}
+		result = append(result, challenge)
+	}
+	if err := rows.Err(); err != nil {
+		return result, total, err
 	}
+	return result, total, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
 
+// ListChallengesWithoutMetadata returns the challenges uploaded before the metadata of challenge.yml was stored
+func ListChallengesWithoutMetadata() ([]Challenge, error) {
+	var result []Challenge
+
+	rows, err := Db.Query("SELECT " + challengeColumns + " FROM challenges WHERE name = '';")
 	if err != nil {
 		return result, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var challenge Challenge
-		err := rows.Scan(&challenge.Id, &challenge.UserId, &challenge.Published, &challenge.CtfdId, &challenge.Verified)
+		challenge, err := scanChallenge(rows)
 		if err != nil {
 			return result, err
 		}
@@ -179,14 +370,43 @@ func ListChallenges(isAdmin bool, userId string) ([]Challenge, error) {
 	return result, nil
 }
 
+func UpdateChallengeMetadata(challengeId string, config ChallengeCtfd) error {
+	return updateChallengeMetadata(Db, challengeId, config)
+}
+
+// updateChallengeMetadata copies the metadata of challenge.yml to the challenge.
+// The state is only taken until the challenge is published, afterwards it is changed with the hide and unhide endpoints.
+func updateChallengeMetadata(db execer, challengeId string, config ChallengeCtfd) error {
+	tags := config.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	_, err := db.Exec("UPDATE challenges SET name=$1, category=$2, author=$3, value=$4, tags=$5, "+
+		"state=CASE WHEN EXISTS (SELECT 1 FROM challenge_publications WHERE challenge_id=$7) THEN state ELSE $6 END WHERE id=$7",
+		config.Name, config.Category, config.Author, config.Value, pq.Array(tags), config.InitialState(), challengeId)
+	return err
+}
+
 func CreateChallenge(userId string) (string, error) {
 	lastInsertId := ""
 	err := Db.QueryRow("INSERT INTO challenges (user_id) VALUES ($1) RETURNING id", userId).Scan(&lastInsertId)
 	return lastInsertId, err
 }
 
-func PublishChallengeWithReference(challengeId string, ctfdId int) error {
-	_, err := Db.Exec("UPDATE challenges SET published=$1, ctfd_id=$2 WHERE id=$3", true, ctfdId, challengeId)
+// PublishChallengeWithReference records the ID of the challenge on the default target and the state it was published with
+func PublishChallengeWithReference(challengeId string, ctfdId int, state string) error {
+	_, err := Db.Exec("UPDATE challenges SET published=$1, ctfd_id=$2, state=$3 WHERE id=$4", true, ctfdId, state, challengeId)
+	return err
+}
+
+// UnpublishChallenge forgets the CTFd challenge, so publishing again creates a new one
+func UnpublishChallenge(challengeId string) error {
+	_, err := Db.Exec("UPDATE challenges SET published=$1, ctfd_id=NULL WHERE id=$2", false, challengeId)
+	return err
+}
+
+func SetChallengeState(challengeId, state string) error {
+	_, err := Db.Exec("UPDATE challenges SET state=$1 WHERE id=$2", state, challengeId)
 	return err
 }
 
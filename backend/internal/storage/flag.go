@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	FlagTypeStatic          = "static"
+	FlagTypeRegex           = "regex"
+	FlagTypeCaseInsensitive = "case_insensitive"
+	FlagDataCaseInsensitive = "case_insensitive"
+)
+
+// Normalize maps every supported notation to a static or regex flag.
+// The "case_insensitive" type is shorthand for a static flag with the case_insensitive data option.
+func (f FlagElement) Normalize() (FlagClass, error) {
+	if f.String != nil {
+		return FlagClass{Type: FlagTypeStatic, Content: *f.String}, nil
+	}
+	if f.FlagClass == nil {
+		return FlagClass{}, fmt.Errorf("empty flag")
+	}
+
+	flag := *f.FlagClass
+	if flag.Content == "" {
+		return FlagClass{}, fmt.Errorf("flag content is empty")
+	}
+	if flag.Data != nil && *flag.Data == "" {
+		flag.Data = nil
+	}
+	if flag.Data != nil && *flag.Data != FlagDataCaseInsensitive {
+		return FlagClass{}, fmt.Errorf("unknown flag data: '%s'", *flag.Data)
+	}
+
+	switch flag.Type {
+	case "", FlagTypeStatic:
+		flag.Type = FlagTypeStatic
+	case FlagTypeCaseInsensitive:
+		data := FlagDataCaseInsensitive
+		flag.Type = FlagTypeStatic
+		flag.Data = &data
+	case FlagTypeRegex:
+		if _, err := flag.compile(); err != nil {
+			return FlagClass{}, fmt.Errorf("invalid regex flag: %v", err)
+		}
+	default:
+		return FlagClass{}, fmt.Errorf("unknown flag type: '%s'", flag.Type)
+	}
+	return flag, nil
+}
+
+func (f FlagClass) CaseInsensitive() bool {
+	return f.Data != nil && *f.Data == FlagDataCaseInsensitive
+}
+
+// Regex flags must match the whole submission, like CTFd does
+func (f FlagClass) compile() (*regexp.Regexp, error) {
+	pattern := "^(?:" + f.Content + ")$"
+	if f.CaseInsensitive() {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+func (f FlagClass) Matches(submission string) bool {
+	switch f.Type {
+	case FlagTypeRegex:
+		re, err := f.compile()
+		if err != nil {
+			return false
+		}
+		return re.MatchString(submission)
+	default:
+		if f.CaseInsensitive() {
+			return strings.EqualFold(f.Content, submission)
+		}
+		return f.Content == submission
+	}
+}
+
+// FoundIn reports whether the flag appears anywhere in the content
+func (f FlagClass) FoundIn(content []byte) bool {
+	switch f.Type {
+	case FlagTypeRegex:
+		pattern := f.Content
+		if f.CaseInsensitive() {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false
+		}
+		return re.Match(content)
+	default:
+		if f.CaseInsensitive() {
+			return bytes.Contains(bytes.ToLower(content), bytes.ToLower([]byte(f.Content)))
+		}
+		return bytes.Contains(content, []byte(f.Content))
+	}
+}
+
+func GetChallengeFlags(challengeId string) ([]FlagClass, error) {
+	return getChallengeFlags(Db, challengeId)
+}
+
+func getChallengeFlags(db querier, challengeId string) ([]FlagClass, error) {
+	var result []FlagClass
+
+	rows, err := db.Query("SELECT type, content, data FROM flags WHERE challenge_id=$1 ORDER BY position;", challengeId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var flag FlagClass
+		var data sql.NullString
+		err := rows.Scan(&flag.Type, &flag.Content, &data)
+		if err != nil {
+			return result, err
+		}
+		if data.Valid {
+			flag.Data = &data.String
+		}
+		result = append(result, flag)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func ReplaceChallengeFlags(challengeId string, flags []FlagClass) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = replaceChallengeFlags(tx, challengeId, flags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceChallengeFlags(db execer, challengeId string, flags []FlagClass) error {
+	_, err := db.Exec("DELETE FROM flags WHERE challenge_id=$1", challengeId)
+	if err != nil {
+		return err
+	}
+
+	for i, flag := range flags {
+		_, err = db.Exec("INSERT INTO flags (challenge_id, position, type, content, data) VALUES ($1, $2, $3, $4, $5)", challengeId, i, flag.Type, flag.Content, flag.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyChallengeFlag accepts the submission if any of the challenge flags match
+func VerifyChallengeFlag(challengeId, submission string) (bool, error) {
+	flags, err := GetChallengeFlags(challengeId)
+	if err != nil {
+		return false, err
+	}
+
+	for _, flag := range flags {
+		if flag.Matches(submission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import "testing"
+
+func TestFlagClassMatches(t *testing.T) {
+	caseInsensitive := FlagDataCaseInsensitive
+	tests := []struct {
+		name       string
+		flag       FlagClass
+		submission string
+		want       bool
+	}{
+		{"static", FlagClass{Type: FlagTypeStatic, Content: "CTF{flag}"}, "CTF{flag}", true},
+		{"static wrong case", FlagClass{Type: FlagTypeStatic, Content: "CTF{flag}"}, "ctf{flag}", false},
+		{"static prefix", FlagClass{Type: FlagTypeStatic, Content: "CTF{flag}"}, "CTF{flag}x", false},
+		{"static case insensitive", FlagClass{Type: FlagTypeStatic, Content: "CTF{flag}", Data: &caseInsensitive}, "ctf{FLAG}", true},
+		{"regex", FlagClass{Type: FlagTypeRegex, Content: `CTF\{[0-9]+\}`}, "CTF{123}", true},
+		{"regex must match whole submission", FlagClass{Type: FlagTypeRegex, Content: `CTF\{[0-9]+\}`}, "xCTF{123}x", false},
+		{"regex alternatives are anchored", FlagClass{Type: FlagTypeRegex, Content: `a|b`}, "ab", false},
+		{"regex wrong case", FlagClass{Type: FlagTypeRegex, Content: `CTF\{[a-z]+\}`}, "ctf{abc}", false},
+		{"regex case insensitive", FlagClass{Type: FlagTypeRegex, Content: `CTF\{[a-z]+\}`, Data: &caseInsensitive}, "ctf{ABC}", true},
+		{"invalid regex", FlagClass{Type: FlagTypeRegex, Content: `CTF{(`}, "CTF{(", false},
+	}
+	for _, test := range tests {
+		if got := test.flag.Matches(test.submission); got != test.want {
+			t.Errorf("%s: Matches(%q) = %t, want %t", test.name, test.submission, got, test.want)
+		}
+	}
+}
+
+func TestFlagElementNormalize(t *testing.T) {
+	plain := "CTF{plain}"
+	unknown := "unknown"
+
+	flag, err := FlagElement{String: &plain}.Normalize()
+	if err != nil || flag.Type != FlagTypeStatic || flag.Content != plain || flag.CaseInsensitive() {
+		t.Errorf("plain string normalized to %+v, %v", flag, err)
+	}
+
+	flag, err = FlagElement{FlagClass: &FlagClass{Type: FlagTypeCaseInsensitive, Content: "CTF{x}"}}.Normalize()
+	if err != nil || flag.Type != FlagTypeStatic || !flag.CaseInsensitive() {
+		t.Errorf("case_insensitive type normalized to %+v, %v", flag, err)
+	}
+
+	invalid := []FlagElement{
+		{},
+		{FlagClass: &FlagClass{Type: FlagTypeStatic}},
+		{FlagClass: &FlagClass{Type: "other", Content: "x"}},
+		{FlagClass: &FlagClass{Type: FlagTypeStatic, Content: "x", Data: &unknown}},
+		{FlagClass: &FlagClass{Type: FlagTypeRegex, Content: "("}},
+	}
+	for _, element := range invalid {
+		if flag, err := element.Normalize(); err == nil {
+			t.Errorf("invalid flag normalized to %+v", flag)
+		}
+	}
+}
@@ -2,18 +2,29 @@ package storage
 
 import (
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type Instance struct {
-	Id          string    `json:"id"`
-	ChallengeId string    `json:"challenge_id"`
-	PlayerId    string    `json:"player_id"`
-	Token       string    `json:"token"`
-	CreatedAt   time.Time `json:"created_at"`
+	Id          string `json:"id"`
+	ChallengeId string `json:"challenge_id"`
+	PlayerId    string `json:"player_id"`
+	Token       string `json:"token"`
+	Flag        string `json:"flag"`
+	// Revision the instance runs, its files are downloaded from this revision. 0 for instances started before it was recorded.
+	Revision  int       `json:"revision"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
+const defaultFlagPrefix = "flag"
+
+var flagPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
 // ? what is the purpose of the token?
 func createToken(length int) (token string, err error) {
 	data := make([]byte, length)
@@ -25,24 +36,85 @@ func createToken(length int) (token string, err error) {
 	return enc, nil
 }
 
-func CreateInstance(userId, challengeId string) (string, string, error) {
-	lastInsertId := ""
+// Reuse the prefix of the author's flag (e.g. "CTF" in "CTF{...}") so dynamic flags look the same
+func dynamicFlagPrefix(challengeId string) string {
+	flags, err := GetChallengeFlags(challengeId)
+	if err != nil {
+		return defaultFlagPrefix
+	}
+	for _, flag := range flags {
+		if flag.Type != FlagTypeStatic {
+			continue
+		}
+		prefix, _, found := strings.Cut(flag.Content, "{")
+		if found && flagPrefixPattern.MatchString(prefix) {
+			return prefix
+		}
+	}
+	return defaultFlagPrefix
+}
+
+func createFlag(challengeId string) (string, error) {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return dynamicFlagPrefix(challengeId) + "{" + hex.EncodeToString(data) + "}", nil
+}
+
+func CreateInstance(userId, challengeId string, revision int) (Instance, error) {
 	token, err := createToken(32)
 	if err != nil {
-		return "", "", err
+		return Instance{}, err
+	}
+	flag, err := createFlag(challengeId)
+	if err != nil {
+		return Instance{}, err
 	}
-	err = Db.QueryRow("INSERT INTO instances (challenge_id, player_id, token) VALUES ($1, $2, $3) RETURNING id", challengeId, userId, token).Scan(&lastInsertId)
+
+	result := Instance{
+		ChallengeId: challengeId,
+		PlayerId:    userId,
+		Token:       token,
+		Flag:        flag,
+		Revision:    revision,
+	}
+	err = Db.QueryRow("INSERT INTO instances (challenge_id, player_id, token, flag, revision) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at", challengeId, userId, token, flag, revision).
+		Scan(&result.Id, &result.CreatedAt)
 	if err != nil {
-		return "", "", err
+		return Instance{}, err
 	}
 
-	return lastInsertId, token, nil
+	return result, nil
 }
 
-func GetInstance(challengeId, token string) (Instance, error) {
+const instanceColumns = "id, challenge_id, player_id, token, flag, COALESCE(revision, 0), created_at"
+
+func scanInstance(row *sql.Row) (Instance, error) {
 	var result Instance
+	var flag sql.NullString
 
-	err := Db.QueryRow("SELECT id, challenge_id, player_id, token, created_at FROM instances WHERE challenge_id = $1 AND token = $2", challengeId, token).
-		Scan(&result.Id, &result.ChallengeId, &result.PlayerId, &result.Token, &result.CreatedAt)
+	err := row.Scan(&result.Id, &result.ChallengeId, &result.PlayerId, &result.Token, &flag, &result.Revision, &result.CreatedAt)
+	result.Flag = flag.String
 	return result, err
 }
+
+func GetInstance(challengeId, token string) (Instance, error) {
+	return scanInstance(Db.QueryRow("SELECT "+instanceColumns+" FROM instances WHERE challenge_id = $1 AND token = $2", challengeId, token))
+}
+
+func GetInstanceById(instanceId string) (Instance, error) {
+	return scanInstance(Db.QueryRow("SELECT "+instanceColumns+" FROM instances WHERE id = $1", instanceId))
+}
+
+// VerifyInstanceFlag accepts the flag of any instance the player started for the challenge,
+// so a restart does not invalidate a flag the player already found
+func VerifyInstanceFlag(challengeId, playerId, submission string) (bool, error) {
+	var count int
+	err := Db.QueryRow("SELECT COUNT(*) FROM instances WHERE challenge_id = $1 AND player_id = $2 AND flag = $3", challengeId, playerId, submission).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
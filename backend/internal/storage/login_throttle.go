@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"time"
+)
+
+// LoginFailure is the audit record of a failed login
+type LoginFailure struct {
+	Id        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Ip        string    `json:"ip"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReserveLoginAttempt counts an attempt for the key as failed before it is checked, and returns the failures so far.
+// Counting and reading the lock happen in one statement, so concurrent attempts get different counts and cannot pass the throttle together.
+// A locked key is not counted, the end of its lock is returned instead. Failures before forgetBefore are no longer counted.
+func ReserveLoginAttempt(key string, forgetBefore time.Time) (int, *time.Time, error) {
+	var failures int
+	var lockedUntil *time.Time
+	err := Db.QueryRow("INSERT INTO login_throttles AS t (key, failures) VALUES ($1, 1) ON CONFLICT (key) DO UPDATE SET "+
+		"failures = CASE WHEN t.locked_until > CURRENT_TIMESTAMP THEN t.failures WHEN t.last_failure_at < $2 THEN 1 ELSE t.failures + 1 END, "+
+		"last_failure_at = CASE WHEN t.locked_until > CURRENT_TIMESTAMP THEN t.last_failure_at ELSE CURRENT_TIMESTAMP END "+
+		"RETURNING failures, CASE WHEN locked_until > CURRENT_TIMESTAMP THEN locked_until END;", key, forgetBefore).Scan(&failures, &lockedUntil)
+	return failures, lockedUntil, err
+}
+
+// ReleaseLoginAttempt takes back an attempt reserved for the key that did not fail, with the lock it set if any.
+// A lock set since by another attempt is kept.
+func ReleaseLoginAttempt(key string, lockedUntil *time.Time) error {
+	_, err := Db.Exec("UPDATE login_throttles SET failures = GREATEST(failures - 1, 0), "+
+		"locked_until = CASE WHEN locked_until = $2 THEN NULL ELSE locked_until END WHERE key=$1", key, lockedUntil)
+	return err
+}
+
+func LockLogin(key string, until time.Time) error {
+	_, err := Db.Exec("UPDATE login_throttles SET locked_until=$1 WHERE key=$2", until, key)
+	return err
+}
+
+// ResetLoginFailures forgets the failures of the key, e.g. after a successful login
+func ResetLoginFailures(key string) error {
+	_, err := Db.Exec("DELETE FROM login_throttles WHERE key=$1", key)
+	return err
+}
+
+// AddLoginFailure records a failed login. Records before deleteBefore and throttles without recent failures are removed.
+func AddLoginFailure(username, ip, reason string, deleteBefore, forgetBefore time.Time) error {
+	_, err := Db.Exec("INSERT INTO login_failures (username, ip, reason) VALUES (LEFT($1, 255), LEFT($2, 64), $3)", username, ip, reason)
+	if err != nil {
+		return err
+	}
+	_, err = Db.Exec("DELETE FROM login_failures WHERE created_at < $1", deleteBefore)
+	if err != nil {
+		return err
+	}
+	_, err = Db.Exec("DELETE FROM login_throttles WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)", forgetBefore)
+	return err
+}
+
+// ListLoginFailures returns the latest failed logins, of every username if username is empty
+func ListLoginFailures(username string, limit int) ([]LoginFailure, error) {
+	var result []LoginFailure
+
+	rows, err := Db.Query("SELECT id, username, ip, reason, created_at FROM login_failures WHERE $1 = '' OR username = $1 "+
+		"ORDER BY created_at DESC, id DESC LIMIT $2;", username, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var failure LoginFailure
+		err := rows.Scan(&failure.Id, &failure.Username, &failure.Ip, &failure.Reason, &failure.CreatedAt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, failure)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
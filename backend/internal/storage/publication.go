@@ -0,0 +1,78 @@
+package storage
+
+import "time"
+
+// Publication is a challenge published to one publish target
+type Publication struct {
+	ChallengeId string `json:"challenge_id"`
+	Target      string `json:"target"`
+	// ID of the challenge on the platform of the target
+	CtfdId      int       `json:"ctfd_id"`
+	Revision    int       `json:"revision"`
+	State       string    `json:"state"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+const publicationColumns = "challenge_id, target, ctfd_id, revision, state, published_at"
+
+func scanPublication(row rowScanner) (Publication, error) {
+	var result Publication
+	err := row.Scan(&result.ChallengeId, &result.Target, &result.CtfdId, &result.Revision, &result.State, &result.PublishedAt)
+	return result, err
+}
+
+func GetPublication(challengeId, target string) (Publication, error) {
+	return scanPublication(Db.QueryRow("SELECT "+publicationColumns+" FROM challenge_publications WHERE challenge_id=$1 AND target=$2;", challengeId, target))
+}
+
+func ListPublications(challengeId string) ([]Publication, error) {
+	return listPublications(Db, challengeId)
+}
+
+func listPublications(db querier, challengeId string) ([]Publication, error) {
+	var result []Publication
+
+	rows, err := db.Query("SELECT "+publicationColumns+" FROM challenge_publications WHERE challenge_id=$1 ORDER BY target;", challengeId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		publication, err := scanPublication(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, publication)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// SavePublication records that the revision was published to the target with the given ID.
+// The state is only set on the first publication, publishing again keeps the state set by hiding or unhiding.
+func SavePublication(challengeId, target string, ctfdId, revision int, state string) error {
+	_, err := Db.Exec("INSERT INTO challenge_publications (challenge_id, target, ctfd_id, revision, state) VALUES ($1, $2, $3, $4, $5) "+
+		"ON CONFLICT (challenge_id, target) DO UPDATE SET ctfd_id=EXCLUDED.ctfd_id, revision=EXCLUDED.revision, published_at=CURRENT_TIMESTAMP",
+		challengeId, target, ctfdId, revision, state)
+	return err
+}
+
+func SetPublicationState(challengeId, target, state string) error {
+	_, err := Db.Exec("UPDATE challenge_publications SET state=$1 WHERE challenge_id=$2 AND target=$3", state, challengeId, target)
+	return err
+}
+
+func DeletePublication(challengeId, target string) error {
+	_, err := Db.Exec("DELETE FROM challenge_publications WHERE challenge_id=$1 AND target=$2", challengeId, target)
+	return err
+}
+
+// MigratePublications records challenges published before targets existed as publications of the default target
+func MigratePublications(target string) error {
+	_, err := Db.Exec("INSERT INTO challenge_publications (challenge_id, target, ctfd_id, revision, state) "+
+		"SELECT id, $1, ctfd_id, revision, state FROM challenges WHERE ctfd_id IS NOT NULL ON CONFLICT DO NOTHING", target)
+	return err
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type Revision struct {
+	Id          string    `json:"id"`
+	ChallengeId string    `json:"challenge_id"`
+	Number      int       `json:"number"`
+	UserId      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Nil until the revision has been scanned for flag leaks
+	LeakScanPassed *bool      `json:"leak_scan_passed"`
+	Leaks          []FlagLeak `json:"leaks"`
+	// Nil until compose.yaml has been checked against the security policy
+	PolicyCheckPassed *bool             `json:"policy_check_passed"`
+	PolicyViolations  []PolicyViolation `json:"policy_violations"`
+	// Admin who allowed publishing despite the policy violations
+	PolicyOverrideBy *string `json:"policy_override_by"`
+}
+
+// FlagLeak is a place in the published material where a flag was found, or that could not be scanned and may hide one
+type FlagLeak struct {
+	Location string `json:"location"`
+	// Index of the leaked flag in challenge.yml, -1 if the content could not be scanned
+	Flag int `json:"flag"`
+	// Why the content could not be scanned
+	Error string `json:"error,omitempty"`
+}
+
+// PolicyViolation is something compose.yaml asks for that challenges are not allowed to do
+type PolicyViolation struct {
+	// Empty for violations outside of a service, e.g. top-level volumes
+	Service string `json:"service"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+const revisionColumns = "id, challenge_id, number, user_id, created_at, leak_scan_passed, leaks, policy_check_passed, policy_violations, policy_override_by"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// execer runs statements on the database or in a transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// querier runs queries on the database or in a transaction
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// NewRevision is an upload to be stored as the next revision of a challenge
+type NewRevision struct {
+	ChallengeId string
+	UserId      string
+	// Digests of the uploaded files, the revision is set when storing
+	Artifacts []Artifact
+	// StoreBlobs puts the files into the upload store. It runs while their digests are locked,
+	// so removing unreferenced blobs cannot delete them before the artifacts are committed.
+	StoreBlobs       func() error
+	Leaks            []FlagLeak
+	PolicyViolations []PolicyViolation
+	// Parsed challenge.yml, whose flags and metadata become those of the challenge
+	Config ChallengeCtfd
+}
+
+func scanRevision(row rowScanner) (Revision, error) {
+	var result Revision
+	var leaks, violations []byte
+
+	err := row.Scan(&result.Id, &result.ChallengeId, &result.Number, &result.UserId, &result.CreatedAt, &result.LeakScanPassed, &leaks, &result.PolicyCheckPassed, &violations, &result.PolicyOverrideBy)
+	if err != nil {
+		return result, err
+	}
+	if leaks != nil {
+		err = json.Unmarshal(leaks, &result.Leaks)
+		if err != nil {
+			return result, err
+		}
+	}
+	if violations != nil {
+		err = json.Unmarshal(violations, &result.PolicyViolations)
+	}
+	return result, err
+}
+
+// StoreRevision saves the upload as the next revision with its artifacts and check results, and makes it the active one.
+// It happens in one transaction, so a failure does not leave a revision behind or use up its number.
+func StoreRevision(revision NewRevision) (int, error) {
+	flags, err := revision.Config.FlagClasses()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Concurrent uploads to the same challenge wait here, so they get different numbers
+	_, err = tx.Exec("SELECT 1 FROM challenges WHERE id=$1 FOR UPDATE", revision.ChallengeId)
+	if err != nil {
+		return 0, err
+	}
+	var digests []string
+	for _, artifact := range revision.Artifacts {
+		digests = append(digests, artifact.Sha256)
+	}
+	err = lockBlobs(tx, digests)
+	if err != nil {
+		return 0, err
+	}
+	err = revision.StoreBlobs()
+	if err != nil {
+		return 0, err
+	}
+
+	var number int
+	err = tx.QueryRow("SELECT COALESCE(MAX(number), 0) + 1 FROM challenge_revisions WHERE challenge_id=$1;", revision.ChallengeId).Scan(&number)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO challenge_revisions (challenge_id, number, user_id) VALUES ($1, $2, $3)", revision.ChallengeId, number, revision.UserId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, artifact := range revision.Artifacts {
+		artifact.ChallengeId = revision.ChallengeId
+		artifact.Revision = number
+		err = createArtifact(tx, artifact)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = setRevisionLeaks(tx, revision.ChallengeId, number, revision.Leaks)
+	if err != nil {
+		return 0, err
+	}
+	err = setRevisionPolicyViolations(tx, revision.ChallengeId, number, revision.PolicyViolations)
+	if err != nil {
+		return 0, err
+	}
+	err = activateRevision(tx, revision.ChallengeId, number, revision.Config, flags)
+	if err != nil {
+		return 0, err
+	}
+
+	return number, tx.Commit()
+}
+
+// ActivateRevision makes a stored revision the active one, with the flags and metadata of its challenge.yml
+func ActivateRevision(challengeId string, number int, config ChallengeCtfd) error {
+	flags, err := config.FlagClasses()
+	if err != nil {
+		return err
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = activateRevision(tx, challengeId, number, config, flags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func activateRevision(db execer, challengeId string, number int, config ChallengeCtfd, flags []FlagClass) error {
+	err := replaceChallengeFlags(db, challengeId, flags)
+	if err != nil {
+		return err
+	}
+	err = updateChallengeMetadata(db, challengeId, config)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE challenges SET revision=$1 WHERE id=$2", number, challengeId)
+	return err
+}
+
+func GetRevision(challengeId string, number int) (Revision, error) {
+	return scanRevision(Db.QueryRow("SELECT "+revisionColumns+" FROM challenge_revisions WHERE challenge_id=$1 AND number=$2;", challengeId, number))
+}
+
+func ListRevisions(challengeId string) ([]Revision, error) {
+	return listRevisions(Db, challengeId)
+}
+
+func listRevisions(db querier, challengeId string) ([]Revision, error) {
+	return queryRevisions(db, "SELECT "+revisionColumns+" FROM challenge_revisions WHERE challenge_id=$1 ORDER BY number;", challengeId)
+}
+
+func queryRevisions(db querier, query string, args ...any) ([]Revision, error) {
+	var result []Revision
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func SetRevisionLeaks(challengeId string, number int, leaks []FlagLeak) error {
+	return setRevisionLeaks(Db, challengeId, number, leaks)
+}
+
+func setRevisionLeaks(db execer, challengeId string, number int, leaks []FlagLeak) error {
+	if leaks == nil {
+		leaks = []FlagLeak{}
+	}
+	content, err := json.Marshal(leaks)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE challenge_revisions SET leak_scan_passed=$1, leaks=$2 WHERE challenge_id=$3 AND number=$4", len(leaks) == 0, string(content), challengeId, number)
+	return err
+}
+
+func SetRevisionPolicyViolations(challengeId string, number int, violations []PolicyViolation) error {
+	return setRevisionPolicyViolations(Db, challengeId, number, violations)
+}
+
+func setRevisionPolicyViolations(db execer, challengeId string, number int, violations []PolicyViolation) error {
+	if violations == nil {
+		violations = []PolicyViolation{}
+	}
+	content, err := json.Marshal(violations)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE challenge_revisions SET policy_check_passed=$1, policy_violations=$2 WHERE challenge_id=$3 AND number=$4", len(violations) == 0, string(content), challengeId, number)
+	return err
+}
+
+// SetRevisionPolicyOverride records the admin allowing the revision to be published despite its policy violations.
+// A nil userId withdraws the override.
+func SetRevisionPolicyOverride(challengeId string, number int, userId *string) error {
+	_, err := Db.Exec("UPDATE challenge_revisions SET policy_override_by=$1 WHERE challenge_id=$2 AND number=$3", userId, challengeId, number)
+	return err
+}
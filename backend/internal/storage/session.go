@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrSessionInvalid is returned when refreshing with a token that is unknown, expired or revoked
+var ErrSessionInvalid = errors.New("refresh token invalid or expired")
+
+// Session is a login of a local user. Only the hash of its current refresh token is stored.
+type Session struct {
+	Id     string
+	UserId string
+	// Current role of the user
+	Role string
+	// Whether the user has enrolled TOTP
+	TotpEnabled bool
+}
+
+func CreateSession(userId, refreshHash string, expiresAt time.Time) (string, error) {
+	var sessionId string
+	err := Db.QueryRow("INSERT INTO sessions (user_id, refresh_hash, expires_at) VALUES ($1, $2, $3) RETURNING id", userId, refreshHash, expiresAt).Scan(&sessionId)
+	return sessionId, err
+}
+
+// RefreshSession replaces the refresh token of the session, which keeps the expiry set at login. The session must not be revoked or expired,
+// the user not disabled, and the password or role not changed since logging in.
+// A refresh token that was already replaced is being reused, probably because it was stolen, so its session is revoked.
+func RefreshSession(refreshHash, newRefreshHash string) (Session, error) {
+	var result Session
+	err := Db.QueryRow("UPDATE sessions s SET refresh_hash=$2, previous_hash=$1, refreshed_at=CURRENT_TIMESTAMP FROM users u "+
+		"WHERE s.refresh_hash=$1 AND u.id=s.user_id AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP AND NOT u.disabled "+
+		"AND (u.tokens_valid_after IS NULL OR s.created_at >= u.tokens_valid_after) RETURNING s.id, s.user_id, COALESCE(u.role, ''), u.totp_enabled;",
+		refreshHash, newRefreshHash).Scan(&result.Id, &result.UserId, &result.Role, &result.TotpEnabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = Db.Exec("UPDATE sessions SET revoked_at=CURRENT_TIMESTAMP WHERE previous_hash=$1 AND revoked_at IS NULL", refreshHash)
+		if err != nil {
+			return result, err
+		}
+		return result, ErrSessionInvalid
+	}
+	return result, err
+}
+
+// RevokeSession ends a session of the user, its refresh token is no longer accepted
+func RevokeSession(sessionId, userId string) error {
+	_, err := Db.Exec("UPDATE sessions SET revoked_at=CURRENT_TIMESTAMP WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL", sessionId, userId)
+	return err
+}
+
+// RevokeUserSessions ends every session of the user and rejects the access tokens issued before. It returns the number of sessions revoked.
+func RevokeUserSessions(userId string) (int64, error) {
+	tx, err := Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE sessions SET revoked_at=CURRENT_TIMESTAMP WHERE user_id=$1 AND revoked_at IS NULL", userId)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE users SET tokens_valid_after=CURRENT_TIMESTAMP WHERE id=$1", userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// RevokeToken rejects the access token with the jti until it expires. Revocations of expired tokens are removed.
+func RevokeToken(jti string, expiresAt time.Time) error {
+	_, err := Db.Exec("INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING", jti, expiresAt)
+	if err != nil {
+		return err
+	}
+	_, err = Db.Exec("DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP")
+	return err
+}
+
+// IsTokenValid reports in one query whether an access token may still be used: the user exists, is not disabled,
+// and the password or role has not changed since the token was issued, the token was not revoked, and neither was its session.
+// An empty jti or session ID skips that check.
+func IsTokenValid(userId string, issuedAt time.Time, jti, sessionId string) (bool, error) {
+	var valid bool
+	err := Db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id=$1 AND NOT disabled "+
+		"AND (tokens_valid_after IS NULL OR date_trunc('second', tokens_valid_after) <= $2)) "+
+		"AND (NULLIF($3, '') IS NULL OR NOT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti=$3)) "+
+		"AND (NULLIF($4, '') IS NULL OR EXISTS (SELECT 1 FROM sessions WHERE id=NULLIF($4, '')::uuid AND revoked_at IS NULL));",
+		userId, issuedAt, jti, sessionId).Scan(&valid)
+	return valid, err
+}
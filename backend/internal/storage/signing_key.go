@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"deployer/internal/auth"
+	"time"
+)
+
+// ListSigningKeys returns the keys that sign local tokens, newest first
+func ListSigningKeys() ([]auth.SigningKey, error) {
+	var result []auth.SigningKey
+
+	rows, err := Db.Query("SELECT kid, secret, created_at, retired_at FROM signing_keys ORDER BY created_at DESC;")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key auth.SigningKey
+		err := rows.Scan(&key.Kid, &key.Secret, &key.CreatedAt, &key.RetiredAt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, key)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// RotateSigningKey makes the key the one that signs tokens. The previous keys are retired and only verify tokens.
+func RotateSigningKey(key auth.SigningKey) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Before the first rotation JWTSECRET signs the tokens, it is retired in its place
+	_, err = tx.Exec("INSERT INTO signing_keys (kid, secret) SELECT $1, ''::bytea WHERE NOT EXISTS (SELECT 1 FROM signing_keys)", auth.JwtSecretKid)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE signing_keys SET retired_at=CURRENT_TIMESTAMP WHERE retired_at IS NULL")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO signing_keys (kid, secret) VALUES ($1, $2)", key.Kid, key.Secret)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSigningKey removes a retired key, which invalidates the tokens it signed. It returns false if there is no such retired key.
+func DeleteSigningKey(kid string) (bool, error) {
+	res, err := Db.Exec("DELETE FROM signing_keys WHERE kid=$1 AND retired_at IS NOT NULL", kid)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// DeleteExpiredSigningKeys removes the keys retired before the time, whose tokens have all expired
+func DeleteExpiredSigningKeys(retiredBefore time.Time) error {
+	_, err := Db.Exec("DELETE FROM signing_keys WHERE retired_at < $1", retiredBefore)
+	return err
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SharedSubmission is a flag submitted by one player that was generated for the instance of another player
+type SharedSubmission struct {
+	Id                string    `json:"id"`
+	ChallengeId       string    `json:"challenge_id"`
+	PlayerId          string    `json:"player_id"`
+	InstanceId        string    `json:"instance_id"`
+	InstancePlayerId  string    `json:"instance_player_id"`
+	InstanceCreatedAt time.Time `json:"instance_created_at"`
+	SubmittedAt       time.Time `json:"submitted_at"`
+}
+
+// RecordSubmission logs the submission and reports whether the flag belongs to an instance of another player
+func RecordSubmission(challengeId, playerId, flag string, correct bool) (bool, error) {
+	var instanceId, instancePlayerId sql.NullString
+	err := Db.QueryRow("SELECT id, player_id FROM instances WHERE challenge_id = $1 AND flag = $2 LIMIT 1", challengeId, flag).
+		Scan(&instanceId, &instancePlayerId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	shared := instancePlayerId.Valid && instancePlayerId.String != playerId
+	_, err = Db.Exec("INSERT INTO submissions (challenge_id, player_id, flag, correct, instance_id, instance_player_id, shared) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		challengeId, playerId, flag, correct, instanceId, instancePlayerId, shared)
+	return shared, err
+}
+
+func ListSharedSubmissions() ([]SharedSubmission, error) {
+	var result []SharedSubmission
+
+	rows, err := Db.Query(
+		"SELECT s.id, s.challenge_id, s.player_id, s.instance_id, s.instance_player_id, i.created_at, s.created_at " +
+			"FROM submissions s JOIN instances i ON i.id = s.instance_id " +
+			"WHERE s.shared = TRUE ORDER BY s.created_at DESC;",
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var submission SharedSubmission
+		err := rows.Scan(&submission.Id, &submission.ChallengeId, &submission.PlayerId, &submission.InstanceId, &submission.InstancePlayerId, &submission.InstanceCreatedAt, &submission.SubmittedAt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
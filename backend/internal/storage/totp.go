@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SetTotpSecret starts a TOTP enrollment. It returns false if the user already enabled TOTP.
+func SetTotpSecret(userId, secret string) (bool, error) {
+	res, err := Db.Exec("UPDATE users SET totp_secret=$1 WHERE id=$2 AND NOT totp_enabled", secret, userId)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// EnableTotp completes the enrollment with the step of the first valid code and replaces the recovery codes
+func EnableTotp(userId string, step int64, recoveryHashes []string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_enabled=TRUE, totp_last_step=$1 WHERE id=$2", step, userId)
+	if err != nil {
+		return err
+	}
+	err = replaceRecoveryCodes(tx, userId, recoveryHashes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTotp removes the TOTP secret and the recovery codes of the user
+func DisableTotp(userId string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_enabled=FALSE, totp_secret=NULL, totp_last_step=0 WHERE id=$1", userId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id=$1", userId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTotpStep records that the code of the step was used. It returns false if a code of this or a later step was used before.
+func UseTotpStep(userId string, step int64) (bool, error) {
+	res, err := Db.Exec("UPDATE users SET totp_last_step=$1 WHERE id=$2 AND totp_last_step < $1", step, userId)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+func ReplaceRecoveryCodes(userId string, recoveryHashes []string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = replaceRecoveryCodes(tx, userId, recoveryHashes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userId string, recoveryHashes []string) error {
+	_, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id=$1", userId)
+	if err != nil {
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		_, err = tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userId, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the hash as used. It returns false if there is no such code.
+func UseRecoveryCode(userId, hash string) (bool, error) {
+	res, err := Db.Exec("UPDATE recovery_codes SET used_at=CURRENT_TIMESTAMP WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL", userId, hash)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// CreateLoginChallenge stores the token of a login waiting for its TOTP code. Expired challenges are removed.
+func CreateLoginChallenge(userId, tokenHash string, expiresAt time.Time) error {
+	_, err := Db.Exec("INSERT INTO login_challenges (token_hash, user_id, expires_at) VALUES ($1, $2, $3)", tokenHash, userId, expiresAt)
+	if err != nil {
+		return err
+	}
+	_, err = Db.Exec("DELETE FROM login_challenges WHERE expires_at < CURRENT_TIMESTAMP")
+	return err
+}
+
+// GetLoginChallenge returns the user of the login with the token, if it has not expired
+func GetLoginChallenge(tokenHash string) (User, error) {
+	return scanUser(Db.QueryRow("SELECT "+userColumns+" FROM users WHERE id=(SELECT user_id FROM login_challenges "+
+		"WHERE token_hash=$1 AND expires_at > CURRENT_TIMESTAMP);", tokenHash))
+}
+
+// DeleteLoginChallenge ends the login with the token, so it cannot be completed twice. It returns false if it was already completed.
+func DeleteLoginChallenge(tokenHash string) (bool, error) {
+	res, err := Db.Exec("DELETE FROM login_challenges WHERE token_hash=$1", tokenHash)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
@@ -1,15 +1,93 @@
 package storage
 
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrUserExists is returned when creating a user with a username that is already taken
+var ErrUserExists = errors.New("username already exists")
+
 type User struct {
-	Id           string
-	Username     string
-	PasswordHash string
-	Role         string
+	Id           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+	Disabled     bool   `json:"disabled"`
+	// Secret of the enabled or the pending TOTP enrollment
+	TotpSecret  string    `json:"-"`
+	TotpEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const userColumns = "id, username, password_hash, COALESCE(role, ''), disabled, COALESCE(totp_secret, ''), totp_enabled, created_at"
+
+func scanUser(row rowScanner) (User, error) {
+	var result User
+	err := row.Scan(&result.Id, &result.Username, &result.PasswordHash, &result.Role, &result.Disabled,
+		&result.TotpSecret, &result.TotpEnabled, &result.CreatedAt)
+	return result, err
 }
 
 func GetUser(username string) (User, error) {
-	var user User
+	return scanUser(Db.QueryRow("SELECT "+userColumns+" FROM users WHERE username=$1;", username))
+}
+
+func GetUserById(userId string) (User, error) {
+	return scanUser(Db.QueryRow("SELECT "+userColumns+" FROM users WHERE id=$1;", userId))
+}
+
+func ListUsers() ([]User, error) {
+	var result []User
+
+	rows, err := Db.Query("SELECT " + userColumns + " FROM users ORDER BY username;")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
 
-	err := Db.QueryRow("SELECT id, username, password_hash, role FROM users WHERE username=$1;", username).Scan(&user.Id, &user.Username, &user.PasswordHash, &user.Role)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, user)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func CreateUser(username, passwordHash, role string) (User, error) {
+	user, err := scanUser(Db.QueryRow("INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3) RETURNING "+userColumns, username, passwordHash, role))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return user, ErrUserExists
+	}
 	return user, err
 }
+
+// SetUserRole changes the role. Tokens issued before the change carry the old role and are no longer accepted.
+func SetUserRole(userId, role string) error {
+	_, err := Db.Exec("UPDATE users SET role=$1, tokens_valid_after=CURRENT_TIMESTAMP WHERE id=$2", role, userId)
+	return err
+}
+
+func SetUserDisabled(userId string, disabled bool) error {
+	_, err := Db.Exec("UPDATE users SET disabled=$1 WHERE id=$2", disabled, userId)
+	return err
+}
+
+// SetUserPassword replaces the password. Tokens issued before the change are no longer accepted.
+func SetUserPassword(userId, passwordHash string) error {
+	_, err := Db.Exec("UPDATE users SET password_hash=$1, tokens_valid_after=CURRENT_TIMESTAMP WHERE id=$2", passwordHash, userId)
+	return err
+}
+
+func DeleteUser(userId string) error {
+	_, err := Db.Exec("DELETE FROM users WHERE id=$1", userId)
+	return err
+}
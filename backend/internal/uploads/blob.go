@@ -0,0 +1,48 @@
+package uploads
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+)
+
+const blobsDir = "blobs/sha256"
+
+func BlobKey(digest string) string {
+	return path.Join(blobsDir, digest)
+}
+
+// Digest returns the SHA-256 digest of the content, which is its key in the store, and its size
+func Digest(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// PutBlob stores the content under its SHA-256 digest. Identical content is only stored once.
+func PutBlob(ctx context.Context, r io.ReadSeeker) (string, int64, error) {
+	digest, size, err := Digest(r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	store := GetStoreSingleton()
+	exists, err := store.Exists(ctx, BlobKey(digest))
+	if err != nil {
+		return "", 0, err
+	}
+	if exists {
+		return digest, size, nil
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", 0, err
+	}
+	return digest, size, store.Put(ctx, BlobKey(digest), r)
+}
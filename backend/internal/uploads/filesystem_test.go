@@ -0,0 +1,25 @@
+package uploads
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	testStore(t, NewFileStore(t.TempDir()))
+}
+
+func TestFileStoreRejectsKeysOutsideRoot(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	ctx := context.Background()
+
+	for _, key := range []string{"../outside", "/etc/passwd", "a/../../outside"} {
+		if err := store.Put(ctx, key, strings.NewReader("x")); err == nil {
+			t.Errorf("Put %s succeeded", key)
+		}
+	}
+	if err := store.DeletePrefix(ctx, "."); err == nil {
+		t.Error("DeletePrefix of the root succeeded")
+	}
+}
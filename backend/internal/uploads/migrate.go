@@ -0,0 +1,143 @@
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"deployer/config"
+	"deployer/internal/storage"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// MigrateLegacyLayout moves files uploaded before revisions existed into the first revision.
+// Only the filesystem store can contain the legacy layout.
+func MigrateLegacyLayout() error {
+	if config.Values.UploadStore != "" && config.Values.UploadStore != StoreFilesystem {
+		return nil
+	}
+
+	entries, err := os.ReadDir(config.Values.UploadPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		challengeId := entry.Name()
+		src := filepath.Join(config.Values.UploadPath, challengeId)
+		if _, err := os.Stat(filepath.Join(src, revisionsDir)); err == nil {
+			continue
+		}
+
+		files, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(src, revisionsDir, "1")
+		err = os.MkdirAll(dst, 0750)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			err = os.Rename(filepath.Join(src, file.Name()), filepath.Join(dst, file.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		log.Printf("Moved uploads of %s to revision 1", challengeId)
+	}
+	return nil
+}
+
+// MigrateRevisionArtifacts moves files stored by revision path into content addressed blobs
+func MigrateRevisionArtifacts(ctx context.Context) error {
+	revisions, err := storage.ListRevisionsWithoutArtifacts()
+	if err != nil {
+		return err
+	}
+
+	store := GetStoreSingleton()
+	for _, revision := range revisions {
+		dir := RevisionDir(revision.ChallengeId, revision.Number)
+		keys, err := store.List(ctx, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			content, err := ReadFile(ctx, key)
+			if err != nil {
+				return err
+			}
+			digest, size, err := PutBlob(ctx, bytes.NewReader(content))
+			if err != nil {
+				return err
+			}
+			err = storage.CreateArtifact(storage.Artifact{
+				ChallengeId: revision.ChallengeId,
+				Revision:    revision.Number,
+				Filename:    path.Base(key),
+				Sha256:      digest,
+				Size:        size,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = store.DeletePrefix(ctx, dir)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			log.Printf("Moved %d files of %s revision %d to blobs", len(keys), revision.ChallengeId, revision.Number)
+		}
+	}
+	return nil
+}
+
+// MigrateChallengeMetadata copies name, category and the other listed fields from challenge.yml
+// of challenges uploaded before they were stored in the database
+func MigrateChallengeMetadata(ctx context.Context) error {
+	challenges, err := storage.ListChallengesWithoutMetadata()
+	if err != nil {
+		return err
+	}
+
+	for _, challenge := range challenges {
+		artifact, err := storage.GetArtifact(challenge.Id, challenge.Revision, "challenge.yml")
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		content, err := ReadFile(ctx, BlobKey(artifact.Sha256))
+		if err != nil {
+			return err
+		}
+		conf, err := storage.ParseChallengeYAML(content)
+		if err != nil {
+			log.Printf("Could not read the metadata of %s: %v", challenge.Id, err)
+			continue
+		}
+		err = storage.UpdateChallengeMetadata(challenge.Id, conf)
+		if err != nil {
+			return err
+		}
+		log.Printf("Stored the metadata of %s", challenge.Id)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testStore checks the behaviour every Store implementation must have
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	files := map[string]string{
+		"blobs/sha256/aa":           "first",
+		"blobs/sha256/bb":           "second",
+		"revisions/1/challenge.yml": "name: test",
+	}
+	for key, content := range files {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	for key, content := range files {
+		reader, size, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", key, err)
+		}
+		if string(got) != content || size != int64(len(content)) {
+			t.Errorf("Get %s = %q (%d bytes), want %q", key, got, size, content)
+		}
+	}
+
+	if _, _, err := store.Get(ctx, "blobs/sha256/cc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	exists, err := store.Exists(ctx, "blobs/sha256/aa")
+	if err != nil || !exists {
+		t.Errorf("Exists of a stored key = %t, %v", exists, err)
+	}
+	exists, err = store.Exists(ctx, "blobs/sha256/cc")
+	if err != nil || exists {
+		t.Errorf("Exists of a missing key = %t, %v", exists, err)
+	}
+
+	keys, err := store.List(ctx, "blobs/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	slices.Sort(keys)
+	if want := []string{"blobs/sha256/aa", "blobs/sha256/bb"}; !slices.Equal(keys, want) {
+		t.Errorf("List = %v, want %v", keys, want)
+	}
+
+	if err := store.DeletePrefix(ctx, "blobs"); err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	keys, err = store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"revisions/1/challenge.yml"}; !slices.Equal(keys, want) {
+		t.Errorf("List after DeletePrefix = %v, want %v", keys, want)
+	}
+	if _, _, err := store.Get(ctx, "blobs/sha256/aa"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted key returned %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,80 @@
+package uploads
+
+import (
+	"context"
+	"deployer/config"
+	"errors"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"sync"
+)
+
+const (
+	StoreFilesystem = "filesystem"
+	StoreS3         = "s3"
+)
+
+const revisionsDir = "revisions"
+
+var ErrNotFound = errors.New("file not found")
+
+// Store holds uploaded challenge files. Keys are slash separated paths relative to the store root.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns the content and size of the file, or ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// List returns the keys of all files below the prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+	// DeletePrefix removes all files below the prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+var instance Store
+var once sync.Once
+
+func GetStoreSingleton() Store {
+	once.Do(func() {
+		var err error
+		instance, err = newStore()
+		if err != nil {
+			log.Fatalf("Failed to create upload store: %v", err)
+		}
+	})
+	return instance
+}
+
+func newStore() (Store, error) {
+	switch config.Values.UploadStore {
+	case "", StoreFilesystem:
+		return NewFileStore(config.Values.UploadPath), nil
+	case StoreS3:
+		return NewS3Store(config.Values.S3)
+	default:
+		return nil, errors.New("unknown upload store: " + config.Values.UploadStore)
+	}
+}
+
+func ChallengeDir(challengeId string) string {
+	return challengeId + "/"
+}
+
+func RevisionDir(challengeId string, revision int) string {
+	return path.Join(challengeId, revisionsDir, strconv.Itoa(revision)) + "/"
+}
+
+func RevisionFile(challengeId string, revision int, filename string) string {
+	return path.Join(challengeId, revisionsDir, strconv.Itoa(revision), filename)
+}
+
+// ReadFile reads a whole file from the store
+func ReadFile(ctx context.Context, key string) ([]byte, error) {
+	reader, _, err := GetStoreSingleton().Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
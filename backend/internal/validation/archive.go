@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"archive/zip"
+	"bytes"
+	"deployer/config"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var archiveFilenames = []string{"challenge.zip", "handout.zip", "solution.zip"}
+
+var ErrNotInArchive = errors.New("file not found in archive")
+
+func openArchive(content []byte) (*zip.Reader, error) {
+	return zip.NewReader(bytes.NewReader(content), int64(len(content)))
+}
+
+// unsafeEntryName reports entries that would be extracted outside the target directory (zip slip)
+func unsafeEntryName(name string) bool {
+	if strings.Contains(name, `\`) || strings.HasPrefix(name, "/") {
+		return true
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func checkArchives(upload Upload, report *Report) {
+	for _, filename := range archiveFilenames {
+		content, ok := upload[filename]
+		if !ok {
+			continue
+		}
+
+		reader, err := openArchive(content)
+		if err != nil {
+			report.Errorf(filename, "not a valid zip archive: %v", err)
+			continue
+		}
+
+		if len(reader.File) > config.Values.UploadLimits.MaxArchiveEntries {
+			report.Errorf(filename, "archive has %d entries, the limit is %d", len(reader.File), config.Values.UploadLimits.MaxArchiveEntries)
+		}
+
+		var uncompressed uint64
+		for _, file := range reader.File {
+			uncompressed += file.UncompressedSize64
+			if unsafeEntryName(file.Name) {
+				report.Errorf(filename, "entry '%s' points outside the archive", file.Name)
+			}
+			if mode := file.Mode(); !mode.IsDir() && !mode.IsRegular() {
+				report.Errorf(filename, "entry '%s' is not a regular file or directory", file.Name)
+			}
+		}
+		if uncompressed > uint64(config.Values.UploadLimits.MaxUncompressedBytes) {
+			report.Errorf(filename, "archive extracts to %d bytes, the limit is %d", uncompressed, config.Values.UploadLimits.MaxUncompressedBytes)
+		}
+	}
+}
+
+// hasRootFile reports whether the archive contains the file at its root, where the init scripts expect it
+func hasRootFile(content []byte, name string) bool {
+	reader, err := openArchive(content)
+	if err != nil {
+		return false
+	}
+	for _, file := range reader.File {
+		if path.Clean(file.Name) == name && !file.Mode().IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadArchiveFile returns the content of a file in the archive, or ErrNotInArchive
+func ReadArchiveFile(content []byte, name string) ([]byte, error) {
+	reader, err := openArchive(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range reader.File {
+		if path.Clean(file.Name) != path.Clean(name) || file.Mode().IsDir() {
+			continue
+		}
+		src, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		return io.ReadAll(src)
+	}
+	return nil, ErrNotInArchive
+}
+
+func checkChallengeArchive(upload Upload, report *Report) {
+	content, ok := upload["challenge.zip"]
+	if ok && !hasRootFile(content, "compose.yaml") {
+		report.Errorf("challenge.zip", "compose.yaml is missing from the root of the archive")
+	}
+}
+
+func checkSolutionArchive(upload Upload, report *Report) {
+	content, ok := upload["solution.zip"]
+	if ok && !hasRootFile(content, "Dockerfile") {
+		report.Errorf("solution.zip", "Dockerfile is missing from the root of the archive")
+	}
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"archive/zip"
+	"bytes"
+	"deployer/config"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+type zipEntry struct {
+	name    string
+	content string
+	mode    fs.FileMode
+}
+
+func buildZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		if entry.mode != 0 {
+			header.SetMode(entry.mode)
+		}
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func setUploadLimits(t *testing.T, limits config.UploadLimitsConfig) {
+	t.Helper()
+	previous := config.Values.UploadLimits
+	config.Values.UploadLimits = limits
+	t.Cleanup(func() { config.Values.UploadLimits = previous })
+}
+
+func archiveErrors(upload Upload) string {
+	report := NewReport()
+	checkArchives(upload, report)
+	return report.Error()
+}
+
+func TestUnsafeEntryName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"compose.yaml":   false,
+		"src/app.py":     false,
+		"dir/":           false,
+		"a..b/file":      false,
+		"../evil":        true,
+		"src/../../evil": true,
+		"/etc/passwd":    true,
+		`..\evil`:        true,
+		`src\file`:       true,
+		"src/..":         true,
+	} {
+		if got := unsafeEntryName(name); got != want {
+			t.Errorf("unsafeEntryName(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestCheckArchives(t *testing.T) {
+	setUploadLimits(t, config.UploadLimitsConfig{MaxArchiveEntries: 3, MaxUncompressedBytes: 100})
+
+	valid := buildZip(t, zipEntry{name: "compose.yaml", content: "services: {}"}, zipEntry{name: "src/", mode: fs.ModeDir | 0755})
+	if errs := archiveErrors(Upload{"challenge.zip": valid}); errs != "" {
+		t.Errorf("valid archive reported: %s", errs)
+	}
+
+	tests := map[string]struct {
+		content []byte
+		want    string
+	}{
+		"not a zip":     {[]byte("not a zip"), "not a valid zip archive"},
+		"zip slip":      {buildZip(t, zipEntry{name: "../../etc/cron.d/evil"}), "points outside the archive"},
+		"symlink":       {buildZip(t, zipEntry{name: "link", content: "/etc/passwd", mode: fs.ModeSymlink | 0777}), "not a regular file or directory"},
+		"many entries":  {buildZip(t, zipEntry{name: "a"}, zipEntry{name: "b"}, zipEntry{name: "c"}, zipEntry{name: "d"}), "archive has 4 entries, the limit is 3"},
+		"zip bomb size": {buildZip(t, zipEntry{name: "big", content: strings.Repeat("0", 1000)}), "archive extracts to 1000 bytes, the limit is 100"},
+	}
+	for name, test := range tests {
+		errs := archiveErrors(Upload{"handout.zip": test.content})
+		if !strings.Contains(errs, test.want) {
+			t.Errorf("%s: errors %q do not contain %q", name, errs, test.want)
+		}
+		if !strings.HasPrefix(errs, "handout.zip: ") {
+			t.Errorf("%s: errors %q not reported for handout.zip", name, errs)
+		}
+	}
+}
+
+func TestReadArchiveFile(t *testing.T) {
+	content := buildZip(t, zipEntry{name: "./compose.yaml", content: "services: {}"}, zipEntry{name: "dir/", mode: fs.ModeDir | 0755})
+
+	data, err := ReadArchiveFile(content, "compose.yaml")
+	if err != nil || string(data) != "services: {}" {
+		t.Errorf("ReadArchiveFile = %q, %v", data, err)
+	}
+	if _, err := ReadArchiveFile(content, "dir"); err != ErrNotInArchive {
+		t.Errorf("ReadArchiveFile of a directory returned %v, want ErrNotInArchive", err)
+	}
+	if !hasRootFile(content, "compose.yaml") || hasRootFile(content, "Dockerfile") {
+		t.Error("hasRootFile does not find the root files")
+	}
+}
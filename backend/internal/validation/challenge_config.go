@@ -0,0 +1,112 @@
+package validation
+
+import (
+	"deployer/internal/storage"
+	"slices"
+	"unicode/utf8"
+)
+
+// Longest name, category and author the challenges table stores
+const maxMetadataLength = 255
+
+var challengeStates = []string{"", "visible", "hidden"}
+
+var decayFunctions = []string{"linear", "logarithmic"}
+
+func checkChallengeConfig(upload Upload, report *Report) {
+	const filename = "challenge.yml"
+	content, ok := upload[filename]
+	if !ok {
+		return
+	}
+
+	conf, err := storage.ParseChallengeYAML(content)
+	if err != nil {
+		report.Errorf(filename, "%v", err)
+		return
+	}
+
+	if conf.Name == "" {
+		report.Errorf(filename, "name is required")
+	}
+	if conf.Category == "" {
+		report.Errorf(filename, "category is required")
+	}
+	for _, field := range []struct{ name, value string }{{"name", conf.Name}, {"category", conf.Category}, {"author", conf.Author}} {
+		if utf8.RuneCountInString(field.value) > maxMetadataLength {
+			report.Errorf(filename, "%s must not be longer than %d characters", field.name, maxMetadataLength)
+		}
+	}
+	if conf.Type == "" {
+		report.Errorf(filename, "type is required")
+	}
+	if conf.Value < 0 {
+		report.Errorf(filename, "value must not be negative")
+	}
+	if conf.Attempts < 0 {
+		report.Errorf(filename, "attempts must not be negative")
+	}
+	if !slices.Contains(challengeStates, conf.State) {
+		report.Errorf(filename, "state must be 'visible' or 'hidden', got '%s'", conf.State)
+	}
+	if conf.Extra != nil {
+		if !slices.Contains(decayFunctions, conf.Extra.Function) {
+			report.Errorf(filename, "extra.function must be 'linear' or 'logarithmic', got '%s'", conf.Extra.Function)
+		}
+		if conf.Extra.Initial < conf.Extra.Minimum {
+			report.Errorf(filename, "extra.initial must not be lower than extra.minimum")
+		}
+		if conf.Extra.Decay < 0 {
+			report.Errorf(filename, "extra.decay must not be negative")
+		}
+	}
+
+	if len(conf.Flags) == 0 {
+		report.Errorf(filename, "at least one flag is required")
+	}
+	for i, element := range conf.Flags {
+		if _, err := element.Normalize(); err != nil {
+			report.Errorf(filename, "flags[%d]: %v", i, err)
+		}
+	}
+
+	for i, hint := range conf.Hints {
+		if hint.Content() == "" {
+			report.Errorf(filename, "hints[%d]: content is required", i)
+		}
+		if hint.Cost() < 0 {
+			report.Errorf(filename, "hints[%d]: cost must not be negative", i)
+		}
+	}
+
+	for i, prerequisite := range conf.Requirements.Prerequisites {
+		if prerequisite == "" || prerequisite == conf.Name {
+			report.Errorf(filename, "requirements[%d]: '%s' is not a valid prerequisite", i, prerequisite)
+		}
+	}
+
+	checkChallengeFiles(upload, conf, report)
+}
+
+// checkChallengeFiles reports files listed in challenge.yml that cannot be published.
+// Listed files are read from handout.zip, except handout.zip itself.
+func checkChallengeFiles(upload Upload, conf storage.ChallengeCtfd, report *Report) {
+	const filename = "challenge.yml"
+	for i, file := range conf.Files {
+		handout, ok := upload["handout.zip"]
+		if !ok {
+			report.Errorf(filename, "files[%d]: '%s' requires handout.zip", i, file)
+			continue
+		}
+		if file == "handout.zip" {
+			continue
+		}
+		if unsafeEntryName(file) {
+			report.Errorf(filename, "files[%d]: '%s' is not a valid path", i, file)
+			continue
+		}
+		if _, err := ReadArchiveFile(handout, file); err != nil {
+			report.Errorf(filename, "files[%d]: '%s' is not in handout.zip", i, file)
+		}
+	}
+}
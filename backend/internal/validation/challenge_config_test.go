@@ -0,0 +1,30 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckChallengeConfigLengths(t *testing.T) {
+	long := strings.Repeat("é", maxMetadataLength+1)
+	content := "name: " + long + "\ncategory: web\nauthor: " + long + "\ntype: dynamic\nflags:\n  - CTF{x}\n"
+
+	report := NewReport()
+	checkChallengeConfig(Upload{"challenge.yml": []byte(content)}, report)
+	errs := report.Error()
+	for _, want := range []string{"name must not be longer than 255 characters", "author must not be longer than 255 characters"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors %q do not contain %q", errs, want)
+		}
+	}
+	if strings.Contains(errs, "category") {
+		t.Errorf("errors %q report the category", errs)
+	}
+
+	report = NewReport()
+	fits := strings.Repeat("é", maxMetadataLength)
+	checkChallengeConfig(Upload{"challenge.yml": []byte("name: " + fits + "\ncategory: web\ntype: dynamic\nflags:\n  - CTF{x}\n")}, report)
+	if report.HasErrors() {
+		t.Errorf("name of %d characters reported: %s", maxMetadataLength, report.Error())
+	}
+}
@@ -0,0 +1,258 @@
+package validation
+
+import (
+	"deployer/internal/storage"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Host ports the challenge services may publish, see HTTP_PORT and SSH_PORT in infrastructure.BuildVm
+var allowedPorts = []string{"8080", "8022"}
+
+// Variables set by infrastructure.BuildVm when running docker compose
+var composeVariables = map[string]string{
+	"HTTP_PORT": "8080",
+	"SSH_PORT":  "8022",
+}
+
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+	Volumes  map[string]composeVolume  `yaml:"volumes"`
+	// Other compose files are not in the upload check, so including them is not allowed
+	Include interface{} `yaml:"include"`
+}
+
+type composeService struct {
+	Privileged  bool          `yaml:"privileged"`
+	NetworkMode string        `yaml:"network_mode"`
+	Volumes     []interface{} `yaml:"volumes"`
+	Ports       []interface{} `yaml:"ports"`
+	Extends     interface{}   `yaml:"extends"`
+}
+
+type composeVolume struct {
+	DriverOpts map[string]string `yaml:"driver_opts"`
+}
+
+func readComposeFile(upload Upload) ([]byte, error) {
+	content, ok := upload["challenge.zip"]
+	if !ok {
+		return nil, nil
+	}
+	compose, err := ReadArchiveFile(content, "compose.yaml")
+	if errors.Is(err, ErrNotInArchive) {
+		return nil, nil
+	}
+	return compose, err
+}
+
+func parseComposeFile(content []byte) (composeFile, error) {
+	var compose composeFile
+	err := yaml.Unmarshal(content, &compose)
+	return compose, err
+}
+
+func checkComposeFile(upload Upload, report *Report) {
+	content, err := readComposeFile(upload)
+	if err != nil || content == nil {
+		return
+	}
+	_, err = parseComposeFile(content)
+	if err != nil {
+		report.Errorf("challenge.zip", "compose.yaml is invalid: %v", err)
+	}
+}
+
+// CheckComposePolicy lists what compose.yaml asks for beyond what challenges are allowed to do.
+// Violations do not reject the upload, but the revision cannot be published unless an admin overrides the policy.
+func CheckComposePolicy(upload Upload) []storage.PolicyViolation {
+	violations := []storage.PolicyViolation{}
+	add := func(service, rule, format string, args ...any) {
+		violations = append(violations, storage.PolicyViolation{
+			Service: service,
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// A compose.yaml that cannot be checked is treated as violating the policy
+	content, err := readComposeFile(upload)
+	if err != nil {
+		add("", "compose_file", "compose.yaml could not be read: %v", err)
+		return violations
+	}
+	if content == nil {
+		return violations
+	}
+	compose, err := parseComposeFile(content)
+	if err != nil {
+		add("", "compose_file", "compose.yaml could not be parsed: %v", err)
+		return violations
+	}
+
+	if compose.Include != nil {
+		add("", "include", "including other compose files is not allowed, they are not checked")
+	}
+
+	for _, name := range sortedKeys(compose.Services) {
+		service := compose.Services[name]
+		if service.Privileged {
+			add(name, "privileged", "privileged containers are not allowed")
+		}
+		if service.Extends != nil {
+			add(name, "extends", "extending other services is not allowed, they are not checked")
+		}
+		networkMode, unresolved := interpolate(service.NetworkMode)
+		if len(unresolved) > 0 {
+			add(name, "unresolved_variable", "network_mode uses %s, which is not known before the challenge starts", strings.Join(unresolved, ", "))
+		}
+		if networkMode == "host" {
+			add(name, "network_mode", "the host network is not allowed")
+		} else if strings.HasPrefix(networkMode, "container:") || strings.HasPrefix(networkMode, "service:") {
+			add(name, "network_mode", "joining the network of '%s' is not allowed", networkMode)
+		}
+		for _, volume := range service.Volumes {
+			source, bind, unresolved := volumeSource(volume)
+			if len(unresolved) > 0 {
+				add(name, "unresolved_variable", "volume source '%s' uses %s, which is not known before the challenge starts", source, strings.Join(unresolved, ", "))
+			} else if bind {
+				add(name, "host_bind_mount", "bind mount of host path '%s' is not allowed", source)
+			}
+		}
+		for _, port := range service.Ports {
+			published, unresolved, ok := publishedPort(port)
+			if len(unresolved) > 0 {
+				add(name, "unresolved_variable", "port '%v' uses %s, which is not known before the challenge starts", port, strings.Join(unresolved, ", "))
+			} else if !ok {
+				add(name, "ports", "port '%v' must be published on a fixed host port", port)
+			} else if !slices.Contains(allowedPorts, published) {
+				add(name, "ports", "host port %s is not allowed, only %s", published, strings.Join(allowedPorts, " and "))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(compose.Volumes) {
+		volume := compose.Volumes[name]
+		if strings.Contains(volume.DriverOpts["o"], "bind") {
+			add("", "host_bind_mount", "volume '%s' binds the host path '%s'", name, volume.DriverOpts["device"])
+		}
+	}
+
+	return violations
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// interpolate replaces the variables known to be set when the challenge starts. It also returns the other variables:
+// a .env file or the environment may set them, e.g. PWD, so their value cannot be known from compose.yaml.
+// They are replaced by their default, if any.
+func interpolate(value string) (string, []string) {
+	var unresolved []string
+	result := os.Expand(value, func(expression string) string {
+		// $$ is an escaped dollar sign
+		if expression == "$" {
+			return "$"
+		}
+		name, operator, argument := splitVariable(expression)
+		if known, ok := composeVariables[name]; ok {
+			if strings.HasSuffix(operator, "+") {
+				return argument
+			}
+			return known
+		}
+		unresolved = append(unresolved, name)
+		if strings.HasSuffix(operator, "-") {
+			return argument
+		}
+		return ""
+	})
+	return result, unresolved
+}
+
+// splitVariable splits ${NAME:-default} and the other forms of compose into the name, the operator and its argument
+func splitVariable(expression string) (string, string, string) {
+	i := strings.IndexAny(expression, ":-?+")
+	if i < 0 {
+		return expression, "", ""
+	}
+	end := i + 1
+	if expression[i] == ':' && end < len(expression) {
+		end++
+	}
+	return expression[:i], expression[i:end], expression[end:]
+}
+
+// volumeSource returns the source of a volume in short or long syntax, whether it is a host path,
+// and the variables in it that cannot be resolved
+func volumeSource(volume interface{}) (string, bool, []string) {
+	switch v := volume.(type) {
+	case string:
+		raw, found := volumeSourcePart(v)
+		if !found {
+			return "", false, nil
+		}
+		source, unresolved := interpolate(raw)
+		bind := strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~")
+		return source, bind, unresolved
+	case map[interface{}]interface{}:
+		raw, ok := v["source"]
+		if !ok {
+			return "", fmt.Sprint(v["type"]) == "bind", nil
+		}
+		source, unresolved := interpolate(fmt.Sprint(raw))
+		return source, fmt.Sprint(v["type"]) == "bind", unresolved
+	}
+	return "", false, nil
+}
+
+// volumeSourcePart returns what comes before the first colon of a short volume, skipping colons in ${NAME:-default}
+func volumeSourcePart(volume string) (string, bool) {
+	depth := 0
+	for i := 0; i < len(volume); i++ {
+		switch {
+		case strings.HasPrefix(volume[i:], "${"):
+			depth++
+			i++
+		case volume[i] == '}' && depth > 0:
+			depth--
+		case volume[i] == ':' && depth == 0:
+			return volume[:i], true
+		}
+	}
+	return "", false
+}
+
+// publishedPort returns the host port of a port mapping in short or long syntax, and the variables in it that cannot be resolved.
+// Mappings without a fixed host port publish on a random port and are reported as not ok.
+func publishedPort(port interface{}) (string, []string, bool) {
+	switch p := port.(type) {
+	case string:
+		interpolated, unresolved := interpolate(p)
+		mapping, _, _ := strings.Cut(interpolated, "/")
+		parts := strings.Split(mapping, ":")
+		if len(parts) < 2 {
+			return "", unresolved, false
+		}
+		return parts[len(parts)-2], unresolved, true
+	case map[interface{}]interface{}:
+		published, ok := p["published"]
+		if !ok {
+			return "", nil, false
+		}
+		interpolated, unresolved := interpolate(fmt.Sprint(published))
+		return interpolated, unresolved, true
+	}
+	return "", nil, false
+}
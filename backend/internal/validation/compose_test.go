@@ -0,0 +1,137 @@
+package validation
+
+import (
+	"slices"
+	"testing"
+)
+
+func composeUpload(t *testing.T, compose string) Upload {
+	return Upload{"challenge.zip": buildZip(t, zipEntry{name: "compose.yaml", content: compose})}
+}
+
+func policyRules(t *testing.T, upload Upload) []string {
+	var rules []string
+	for _, violation := range CheckComposePolicy(upload) {
+		rules = append(rules, violation.Service+":"+violation.Rule)
+	}
+	return rules
+}
+
+func TestCheckComposePolicy(t *testing.T) {
+	tests := map[string]struct {
+		compose string
+		want    []string
+	}{
+		"allowed": {`
+services:
+  web:
+    image: nginx
+    ports: ["${HTTP_PORT}:80"]
+    volumes: ["data:/data"]
+volumes:
+  data: {}
+`, nil},
+		"privileged and host network": {`
+services:
+  web:
+    privileged: true
+    network_mode: host
+`, []string{"web:privileged", "web:network_mode"}},
+		"bind mounts": {`
+services:
+  web:
+    volumes:
+      - /var/run/docker.sock:/var/run/docker.sock
+      - type: bind
+        source: /etc
+        target: /host
+volumes:
+  host:
+    driver_opts:
+      o: bind
+      device: /
+`, []string{"web:host_bind_mount", "web:host_bind_mount", ":host_bind_mount"}},
+		"ports": {`
+services:
+  web:
+    ports: ["80", "9000:80", {target: 22, published: "8022"}]
+`, []string{"web:ports", "web:ports"}},
+		"unparsable": {"services: [", []string{":compose_file"}},
+		"unresolved volume sources": {`
+services:
+  web:
+    volumes:
+      - ${PWD}:/host
+      - ${DATA:-data}:/data
+      - type: volume
+        source: $NAME
+        target: /data
+      - /anonymous
+      - cache:/cache/${HTTP_PORT}
+`, []string{"web:unresolved_variable", "web:unresolved_variable", "web:unresolved_variable"}},
+		"network mode": {`
+services:
+  a:
+    network_mode: ${NET:-host}
+  b:
+    network_mode: container:abc123
+  c:
+    network_mode: service:a
+  d:
+    network_mode: bridge
+  e:
+    network_mode: $${NET}
+`, []string{"a:unresolved_variable", "a:network_mode", "b:network_mode", "c:network_mode"}},
+		"unresolved port": {`
+services:
+  web:
+    ports: ["${PORT:-8080}:80", "${SSH_PORT}:22"]
+`, []string{"web:unresolved_variable"}},
+		"include and extends": {`
+include:
+  - other.yaml
+services:
+  web:
+    extends:
+      file: base.yaml
+      service: web
+`, []string{":include", "web:extends"}},
+	}
+	for name, test := range tests {
+		if got := policyRules(t, composeUpload(t, test.compose)); !slices.Equal(got, test.want) {
+			t.Errorf("%s: violations %v, want %v", name, got, test.want)
+		}
+	}
+}
+
+func TestCheckComposePolicyUnreadableArchive(t *testing.T) {
+	got := policyRules(t, Upload{"challenge.zip": []byte("not a zip")})
+	if !slices.Equal(got, []string{":compose_file"}) {
+		t.Errorf("violations %v, want the compose file to be reported", got)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		value      string
+		want       string
+		unresolved []string
+	}{
+		{"${HTTP_PORT}:80", "8080:80", nil},
+		{"$SSH_PORT", "8022", nil},
+		{"${HTTP_PORT:-9000}", "8080", nil},
+		{"${HTTP_PORT:+set}", "set", nil},
+		{"${PWD}/data", "/data", []string{"PWD"}},
+		{"${NET:-host}", "host", []string{"NET"}},
+		{"${NET-host}", "host", []string{"NET"}},
+		{"${NET:?required}", "", []string{"NET"}},
+		{"${NET:+bridge}", "", []string{"NET"}},
+		{"$${NET}", "${NET}", nil},
+	}
+	for _, test := range tests {
+		got, unresolved := interpolate(test.value)
+		if got != test.want || !slices.Equal(unresolved, test.unresolved) {
+			t.Errorf("interpolate(%q) = %q, %v, want %q, %v", test.value, got, unresolved, test.want, test.unresolved)
+		}
+	}
+}
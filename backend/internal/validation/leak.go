@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"deployer/config"
+	"deployer/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+)
+
+// Archives nested deeper than this are not opened
+const maxNestingDepth = 5
+
+type leakScanner struct {
+	flags []storage.FlagClass
+	leaks []storage.FlagLeak
+	// Bytes that may still be extracted from nested archives
+	budget int64
+}
+
+// ScanFlagLeaks searches the material shown to players, the handout and the
+// description, hints and connection info of challenge.yml, for the challenge flags
+func ScanFlagLeaks(upload Upload) []storage.FlagLeak {
+	scanner := &leakScanner{
+		leaks:  []storage.FlagLeak{},
+		budget: config.Values.UploadLimits.MaxUncompressedBytes,
+	}
+
+	conf, err := storage.ParseChallengeYAML(upload["challenge.yml"])
+	if err != nil {
+		scanner.unscanned("challenge.yml", err)
+		return scanner.leaks
+	}
+	scanner.flags, err = conf.FlagClasses()
+	if err != nil {
+		scanner.unscanned("challenge.yml", err)
+		return scanner.leaks
+	}
+
+	scanner.scan("challenge.yml:description", []byte(conf.Description))
+	scanner.scan("challenge.yml:connection_info", []byte(conf.ConnectionInfo))
+	for i, hint := range conf.Hints {
+		scanner.scan(fmt.Sprintf("challenge.yml:hints[%d]", i), []byte(hint.Content()))
+	}
+
+	if handout, ok := upload["handout.zip"]; ok {
+		scanner.scanFile("handout.zip", handout, 0)
+	}
+	return scanner.leaks
+}
+
+func (s *leakScanner) scan(location string, content []byte) {
+	for i, flag := range s.flags {
+		leak := storage.FlagLeak{Location: location, Flag: i}
+		if !slices.Contains(s.leaks, leak) && flag.FoundIn(content) {
+			s.leaks = append(s.leaks, leak)
+		}
+	}
+}
+
+// unscanned records content that could not be scanned. It blocks publishing like a leak, as it may hide a flag.
+func (s *leakScanner) unscanned(location string, err error) {
+	log.Printf("Could not scan %s for flags: %v", location, err)
+	s.leaks = append(s.leaks, storage.FlagLeak{Location: location, Flag: -1, Error: err.Error()})
+}
+
+// scanFile scans the content and, if it is an archive, every file inside it
+func (s *leakScanner) scanFile(location string, content []byte, depth int) {
+	s.scan(location, content)
+
+	var scanArchive func() error
+	switch {
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+		scanArchive = func() error { return s.scanZip(location, content, depth) }
+	case bytes.HasPrefix(content, []byte{0x1f, 0x8b}):
+		scanArchive = func() error { return s.scanGzip(location, content, depth) }
+	case len(content) > 262 && string(content[257:262]) == "ustar":
+		scanArchive = func() error { return s.scanTar(location, bytes.NewReader(content), depth) }
+	default:
+		return
+	}
+
+	if depth >= maxNestingDepth {
+		s.unscanned(location, fmt.Errorf("archive is nested deeper than %d levels", maxNestingDepth))
+		return
+	}
+	if err := scanArchive(); err != nil {
+		s.unscanned(location, err)
+	}
+}
+
+func (s *leakScanner) read(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, s.budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > s.budget {
+		return nil, errors.New("extracted content exceeds the size limit")
+	}
+	s.budget -= int64(len(content))
+	return content, nil
+}
+
+func (s *leakScanner) scanZip(location string, content []byte, depth int) error {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		s.scan(location+":"+file.Name, []byte(file.Name))
+
+		src, err := file.Open()
+		if err != nil {
+			return err
+		}
+		data, err := s.read(src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+		s.scanFile(location+":"+file.Name, data, depth+1)
+	}
+	return nil
+}
+
+func (s *leakScanner) scanGzip(location string, content []byte, depth int) error {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err := s.read(reader)
+	if err != nil {
+		return err
+	}
+	s.scanFile(location+":"+reader.Name, data, depth+1)
+	return nil
+}
+
+func (s *leakScanner) scanTar(location string, r io.Reader, depth int) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		s.scan(location+":"+header.Name, []byte(header.Name))
+
+		data, err := s.read(reader)
+		if err != nil {
+			return err
+		}
+		s.scanFile(location+":"+header.Name, data, depth+1)
+	}
+}
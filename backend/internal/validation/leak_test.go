@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"deployer/config"
+	"deployer/internal/storage"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const leakTestConfig = `name: test
+description: Find the flag
+flags:
+  - CTF{secret}
+`
+
+// nestZip wraps the entry in the given number of zip archives
+func nestZip(t *testing.T, entry zipEntry, levels int) []byte {
+	content := buildZip(t, entry)
+	for i := 1; i < levels; i++ {
+		content = buildZip(t, zipEntry{name: "inner.zip", content: string(content)})
+	}
+	return content
+}
+
+func TestScanFlagLeaks(t *testing.T) {
+	setUploadLimits(t, config.UploadLimitsConfig{MaxUncompressedBytes: 1000})
+
+	leaks := ScanFlagLeaks(Upload{
+		"challenge.yml": []byte(leakTestConfig),
+		"handout.zip":   buildZip(t, zipEntry{name: "notes.txt", content: "nothing here"}),
+	})
+	if len(leaks) != 0 {
+		t.Errorf("clean handout reported %v", leaks)
+	}
+
+	leaks = ScanFlagLeaks(Upload{
+		"challenge.yml": []byte(leakTestConfig),
+		"handout.zip":   nestZip(t, zipEntry{name: "flag.txt", content: "CTF{secret}"}, 2),
+	})
+	want := storage.FlagLeak{Location: "handout.zip:inner.zip:flag.txt", Flag: 0}
+	if !slices.Contains(leaks, want) {
+		t.Errorf("leaks %v do not contain %v", leaks, want)
+	}
+
+	leaks = ScanFlagLeaks(Upload{"challenge.yml": []byte(strings.Replace(leakTestConfig, "Find the flag", "The flag is CTF{secret}", 1))})
+	want = storage.FlagLeak{Location: "challenge.yml:description", Flag: 0}
+	if !slices.Contains(leaks, want) {
+		t.Errorf("leaks %v do not contain %v", leaks, want)
+	}
+}
+
+func TestScanFlagLeaksBlocksUnscannedContent(t *testing.T) {
+	setUploadLimits(t, config.UploadLimitsConfig{MaxUncompressedBytes: 100})
+
+	tests := map[string]Upload{
+		"too deep": {
+			"challenge.yml": []byte(leakTestConfig),
+			"handout.zip":   nestZip(t, zipEntry{name: "flag.txt", content: "CTF{secret}"}, maxNestingDepth+2),
+		},
+		"too large": {
+			"challenge.yml": []byte(leakTestConfig),
+			"handout.zip":   buildZip(t, zipEntry{name: "big.txt", content: strings.Repeat("x", 1000)}),
+		},
+		"invalid challenge.yml": {
+			"challenge.yml": []byte("flags: ["),
+		},
+	}
+	for name, upload := range tests {
+		leaks := ScanFlagLeaks(upload)
+		if !slices.ContainsFunc(leaks, func(leak storage.FlagLeak) bool { return leak.Flag == -1 && leak.Error != "" }) {
+			t.Errorf("%s: unscanned content not reported, leaks %v", name, leaks)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileReport lists the problems found in one uploaded file
+type FileReport struct {
+	Filename string   `json:"filename"`
+	Errors   []string `json:"errors"`
+}
+
+// Report collects the problems of an upload, grouped by file
+type Report struct {
+	Files []*FileReport `json:"files"`
+}
+
+func (r *Report) file(filename string) *FileReport {
+	for _, file := range r.Files {
+		if file.Filename == filename {
+			return file
+		}
+	}
+	file := &FileReport{Filename: filename, Errors: []string{}}
+	r.Files = append(r.Files, file)
+	return file
+}
+
+func (r *Report) Errorf(filename, format string, args ...any) {
+	file := r.file(filename)
+	file.Errors = append(file.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) HasErrors() bool {
+	for _, file := range r.Files {
+		if len(file.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) Error() string {
+	var lines []string
+	for _, file := range r.Files {
+		for _, err := range file.Errors {
+			lines = append(lines, file.Filename+": "+err)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Upload maps the uploaded filenames to their content
+type Upload map[string][]byte
+
+// stage inspects the upload and adds its findings to the report
+type stage func(upload Upload, report *Report)
+
+var stages = []stage{
+	checkRequiredFiles,
+	checkArchives,
+	checkChallengeArchive,
+	checkSolutionArchive,
+	checkComposeFile,
+	checkChallengeConfig,
+}
+
+func NewReport() *Report {
+	return &Report{Files: []*FileReport{}}
+}
+
+// ValidateUpload runs every validation stage. The upload must not be stored if the report has errors.
+func ValidateUpload(upload Upload, report *Report) {
+	for _, stage := range stages {
+		stage(upload, report)
+	}
+}
+
+func checkRequiredFiles(upload Upload, report *Report) {
+	for _, filename := range []string{"challenge.yml", "challenge.zip"} {
+		if _, ok := upload[filename]; !ok {
+			report.Errorf(filename, "file is required")
+		}
+	}
+}
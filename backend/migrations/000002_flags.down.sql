@@ -0,0 +1,7 @@
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS flag VARCHAR(255) DEFAULT NULL;
+
+UPDATE challenges SET flag = (
+   SELECT content FROM flags WHERE flags.challenge_id = challenges.id ORDER BY position LIMIT 1
+);
+
+DROP TABLE IF EXISTS flags;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS flags (
+   id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+   challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+   position INTEGER NOT NULL DEFAULT 0,
+   type VARCHAR(255) NOT NULL DEFAULT 'static',
+   content TEXT NOT NULL,
+   data VARCHAR(255) DEFAULT NULL
+);
+
+INSERT INTO flags (challenge_id, type, content)
+SELECT id, 'static', flag FROM challenges WHERE flag IS NOT NULL;
+
+ALTER TABLE challenges DROP COLUMN IF EXISTS flag;
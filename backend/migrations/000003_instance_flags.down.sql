@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS instances_challenge_player_idx;
+
+ALTER TABLE instances DROP COLUMN IF EXISTS flag;
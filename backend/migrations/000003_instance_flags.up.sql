@@ -0,0 +1,3 @@
+ALTER TABLE instances ADD COLUMN IF NOT EXISTS flag VARCHAR(255) DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS instances_challenge_player_idx ON instances (challenge_id, player_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS submissions (
+   id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+   challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+   player_id VARCHAR(255) NOT NULL,
+   flag VARCHAR(255) NOT NULL,
+   correct BOOLEAN NOT NULL DEFAULT FALSE,
+   instance_id UUID DEFAULT NULL REFERENCES instances(id) ON DELETE SET NULL,
+   instance_player_id VARCHAR(255) DEFAULT NULL,
+   shared BOOLEAN NOT NULL DEFAULT FALSE,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS submissions_shared_idx ON submissions (shared, created_at);
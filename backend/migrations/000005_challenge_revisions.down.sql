@@ -0,0 +1,3 @@
+ALTER TABLE challenges DROP COLUMN IF EXISTS revision;
+
+DROP TABLE IF EXISTS challenge_revisions;
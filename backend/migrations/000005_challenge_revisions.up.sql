@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS challenge_revisions (
+   id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+   challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+   number INTEGER NOT NULL,
+   user_id VARCHAR(255) NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   UNIQUE (challenge_id, number)
+);
+
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+
+INSERT INTO challenge_revisions (challenge_id, number, user_id, created_at)
+SELECT id, 1, user_id, created_at FROM challenges;
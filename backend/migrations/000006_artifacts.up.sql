@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS artifacts (
+   challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+   revision INTEGER NOT NULL,
+   filename VARCHAR(255) NOT NULL,
+   sha256 CHAR(64) NOT NULL,
+   size BIGINT NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY (challenge_id, revision, filename)
+);
+
+CREATE INDEX IF NOT EXISTS artifacts_sha256_idx ON artifacts (sha256);
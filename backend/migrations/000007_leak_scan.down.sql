@@ -0,0 +1,2 @@
+ALTER TABLE challenge_revisions DROP COLUMN IF EXISTS leaks;
+ALTER TABLE challenge_revisions DROP COLUMN IF EXISTS leak_scan_passed;
@@ -0,0 +1,2 @@
+ALTER TABLE challenge_revisions ADD COLUMN IF NOT EXISTS leak_scan_passed BOOLEAN DEFAULT NULL;
+ALTER TABLE challenge_revisions ADD COLUMN IF NOT EXISTS leaks TEXT DEFAULT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE challenge_revisions DROP COLUMN IF EXISTS policy_override_by;
+ALTER TABLE challenge_revisions DROP COLUMN IF EXISTS policy_violations;
+ALTER TABLE challenge_revisions DROP COLUMN IF EXISTS policy_check_passed;
@@ -0,0 +1,3 @@
+ALTER TABLE challenge_revisions ADD COLUMN IF NOT EXISTS policy_check_passed BOOLEAN DEFAULT NULL;
+ALTER TABLE challenge_revisions ADD COLUMN IF NOT EXISTS policy_violations TEXT DEFAULT NULL;
+ALTER TABLE challenge_revisions ADD COLUMN IF NOT EXISTS policy_override_by VARCHAR(255) DEFAULT NULL;
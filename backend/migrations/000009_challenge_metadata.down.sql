@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS challenges_tags_idx;
+DROP INDEX IF EXISTS challenges_category_idx;
+
+ALTER TABLE challenges DROP COLUMN IF EXISTS state;
+ALTER TABLE challenges DROP COLUMN IF EXISTS tags;
+ALTER TABLE challenges DROP COLUMN IF EXISTS value;
+ALTER TABLE challenges DROP COLUMN IF EXISTS author;
+ALTER TABLE challenges DROP COLUMN IF EXISTS category;
+ALTER TABLE challenges DROP COLUMN IF EXISTS name;
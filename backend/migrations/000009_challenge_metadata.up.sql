@@ -0,0 +1,9 @@
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS category VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS author VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS value INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS state VARCHAR(16) NOT NULL DEFAULT 'visible';
+
+CREATE INDEX IF NOT EXISTS challenges_category_idx ON challenges (category);
+CREATE INDEX IF NOT EXISTS challenges_tags_idx ON challenges USING GIN (tags);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS challenge_publications (
+   challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+   target VARCHAR(255) NOT NULL,
+   ctfd_id INTEGER NOT NULL,
+   revision INTEGER NOT NULL,
+   state VARCHAR(16) NOT NULL DEFAULT 'visible',
+   published_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY (challenge_id, target)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS challenge_publications_ctfd_id_idx ON challenge_publications (target, ctfd_id);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_valid_after;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled;
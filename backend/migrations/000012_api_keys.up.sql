@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+   id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name VARCHAR(255) NOT NULL,
+   prefix VARCHAR(16) NOT NULL,
+   key_hash CHAR(64) NOT NULL UNIQUE,
+   scopes TEXT[] NOT NULL DEFAULT '{}',
+   expires_at TIMESTAMPTZ DEFAULT NULL,
+   revoked_at TIMESTAMPTZ DEFAULT NULL,
+   last_used_at TIMESTAMPTZ DEFAULT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON api_keys (user_id);
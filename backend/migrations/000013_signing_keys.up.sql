@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+   kid VARCHAR(64) PRIMARY KEY,
+   secret BYTEA NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   retired_at TIMESTAMPTZ DEFAULT NULL
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sessions (
+   id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   refresh_hash CHAR(64) NOT NULL UNIQUE,
+   previous_hash CHAR(64) DEFAULT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   refreshed_at TIMESTAMPTZ DEFAULT NULL,
+   expires_at TIMESTAMPTZ NOT NULL,
+   revoked_at TIMESTAMPTZ DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_previous_hash_idx ON sessions (previous_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+   jti VARCHAR(64) PRIMARY KEY,
+   expires_at TIMESTAMPTZ NOT NULL
+);
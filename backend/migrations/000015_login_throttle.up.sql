@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS login_throttles (
+   key VARCHAR(300) PRIMARY KEY,
+   failures INTEGER NOT NULL DEFAULT 0,
+   last_failure_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   locked_until TIMESTAMPTZ DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS login_failures (
+   id BIGSERIAL PRIMARY KEY,
+   username VARCHAR(255) NOT NULL,
+   ip VARCHAR(64) NOT NULL,
+   reason VARCHAR(32) NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_created_idx ON login_failures (created_at);
@@ -0,0 +1 @@
+ALTER TABLE instances ADD COLUMN IF NOT EXISTS revision INT DEFAULT NULL;